@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+	"workspaces-cli/models/db"
+	"workspaces-cli/pkg/config"
+	"workspaces-cli/pkg/theme"
+)
+
+const DB_USAGE string = "usage: db backup | db list | db restore [-yes] <file>"
+
+// resolveBackup: file is either a path or the name of a snapshot in the backup dir
+func resolveBackup(dir string, file string) (string, error) {
+	if !strings.Contains(file, "/") {
+		if _, err := os.Stat(path.Join(dir, file)); err == nil {
+			return path.Join(dir, file), nil
+		}
+	}
+	if _, err := os.Stat(file); errors.Is(err, fs.ErrNotExist) {
+		return "", fmt.Errorf("no backup '%s', see db list", file)
+	} else if err != nil {
+		return "", err
+	}
+	return file, nil
+}
+
+func listBackups(b db.Backups) error {
+	backups, err := db.ListBackups(b.Dir)
+	if err != nil {
+		return err
+	}
+	if len(backups) == 0 {
+		fmt.Printf("no backups in %s\n", b.Dir)
+		return nil
+	}
+	width := 0
+	for _, bk := range backups {
+		width = max(width, len(path.Base(bk.File)))
+	}
+	fmt.Println(theme.Render(theme.FOOTER, b.Dir))
+	for _, bk := range backups {
+		fmt.Printf("  %-*s  %s  %-9s %6d KiB\n",
+			width, path.Base(bk.File), bk.Date.Local().Format("2006-01-02 15:04"), bk.Reason, (bk.Size+1023)/1024)
+	}
+	return nil
+}
+
+// dbCommand: backups of the sqlite store. they run without the store opened for the ui,
+// a restore replaces the database file.
+func dbCommand(ctx context.Context, cfg config.Config, root string, args []string) error {
+	if cfg.Store != "" && cfg.Store != db.STORE_SQLITE {
+		return fmt.Errorf("backups are for the %s store, this one is %s", db.STORE_SQLITE, cfg.Store)
+	}
+	b, err := backups(cfg)
+	if err != nil {
+		return err
+	}
+	dbfile := path.Join(root, db.SQLITE_FILE)
+	if len(args) == 0 {
+		return errors.New(DB_USAGE)
+	}
+	switch args[0] {
+	case "backup":
+		s, err := db.OpenSQLite(ctx, dbfile, b)
+		if err != nil {
+			return fmt.Errorf("connect db: %w", err)
+		}
+		defer s.Close()
+		bk, err := s.Backup(ctx, b, db.BACKUP_MANUAL)
+		if err != nil {
+			return err
+		}
+		fmt.Println(theme.Render(theme.SUCCESS, fmt.Sprintf("backed up to %s", bk.File)))
+		return nil
+	case "list":
+		return listBackups(b)
+	case "restore":
+		flags := flag.NewFlagSet("restore", flag.ExitOnError)
+		yes := flags.Bool("yes", false, "restore without asking")
+		flags.Parse(args[1:])
+		if flags.NArg() != 1 {
+			return errors.New(DB_USAGE)
+		}
+		from, err := resolveBackup(b.Dir, flags.Arg(0))
+		if err != nil {
+			return err
+		}
+		fmt.Println(theme.Render(theme.WARNING, "close every other workspaces window first, the database file is replaced"))
+		if !*yes && !confirm(bufio.NewReader(os.Stdin), fmt.Sprintf("restore %s from %s", dbfile, from)) {
+			return nil
+		}
+		saved, err := db.Restore(ctx, dbfile, from, b)
+		if err != nil {
+			return err
+		}
+		fmt.Println(theme.Render(theme.SUCCESS, fmt.Sprintf("restored %s", from)))
+		if saved.File != "" {
+			fmt.Printf("the database it replaced is in %s\n", saved.File)
+		}
+		return nil
+	}
+	return errors.New(DB_USAGE)
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"workspaces-cli/models/db"
+	"workspaces-cli/pkg/theme"
+	"workspaces-cli/pkg/workspaces"
+)
+
+// confirm: asks a yes/no question on the terminal, no is the default
+func confirm(in *bufio.Reader, question string) bool {
+	fmt.Printf("  %s? [y/N] ", question)
+	answer, _ := in.ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+// doctor: reports what is wrong with the database and offers the fixes one by one. the
+// history of missing workspaces is only purged when asked for each of them, or with -purge.
+func doctor(ctx context.Context, w []workspaces.Workspace, store db.Store, args []string) error {
+	fs := flag.NewFlagSet("doctor", flag.ExitOnError)
+	yes := fs.Bool("yes", false, "apply every automatic fix without asking")
+	purge := fs.Bool("purge", false, "with -yes, also purge the history of missing workspaces without a fix")
+	dryrun := fs.Bool("dry-run", false, "report the problems without fixing them")
+	fs.Parse(args)
+	d, ok := store.(db.Doctor)
+	if !ok {
+		return fmt.Errorf("the store has nothing to check")
+	}
+	problems, err := d.Diagnose(ctx, w)
+	if err != nil {
+		return fmt.Errorf("diagnose: %w", err)
+	}
+	if len(problems) == 0 {
+		fmt.Println(theme.Render(theme.SUCCESS, "no problems found"))
+		return nil
+	}
+	in := bufio.NewReader(os.Stdin)
+	fixed, backedUp := 0, false
+	for _, p := range problems {
+		fmt.Println(theme.Render(theme.WARNING, p.String()))
+		if *dryrun {
+			fmt.Println(theme.Render(theme.FOOTER, "  "+p.FixDescription()))
+			continue
+		}
+		switch {
+		case p.Fix != db.FIX_NONE:
+			if !*yes && !confirm(in, p.FixDescription()) {
+				continue
+			}
+		case p.Purgeable && *yes && *purge:
+			p = p.Purge()
+		case p.Purgeable && !*yes:
+			fmt.Println(theme.Render(theme.FOOTER, "  "+p.FixDescription()))
+			if !confirm(in, "purge its checkpoints, tags and activity") {
+				continue
+			}
+			p = p.Purge()
+		default:
+			fmt.Println(theme.Render(theme.FOOTER, "  "+p.FixDescription()))
+			continue
+		}
+		if !backedUp {
+			b, err := d.BackupBeforeRepair(ctx)
+			if err != nil {
+				return fmt.Errorf("backup before repair, nothing was changed: %w", err)
+			}
+			if b.File != "" {
+				fmt.Println(theme.Render(theme.FOOTER, "  backed up to "+b.File))
+			}
+			backedUp = true
+		}
+		if err := d.Repair(ctx, p); err != nil {
+			fmt.Println(theme.Render(theme.ERROR, fmt.Sprintf("  %s: %s", p.FixDescription(), err)))
+			continue
+		}
+		fixed++
+		fmt.Println(theme.Render(theme.SUCCESS, "  "+p.FixDescription()+": done"))
+	}
+	fmt.Printf("%d problem(s), %d fixed\n", len(problems), fixed)
+	return nil
+}
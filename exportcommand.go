@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+	"workspaces-cli/models/db"
+	"workspaces-cli/models/export"
+)
+
+// parseDay: a YYYY-MM-DD date at local midnight, zero when empty
+func parseDay(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	return time.ParseInLocation(time.DateOnly, s, time.Local)
+}
+
+// exportFilter: the filter the flags describe, until is inclusive on the command line
+func exportFilter(workspaces, tag, since, until string) (export.Filter, error) {
+	f := export.Filter{Tag: tag}
+	if workspaces != "" {
+		f.Workspaces = strings.Split(workspaces, ",")
+	}
+	var err error
+	if f.Since, err = parseDay(since); err != nil {
+		return f, fmt.Errorf("since: %w", err)
+	}
+	if f.Until, err = parseDay(until); err != nil {
+		return f, fmt.Errorf("until: %w", err)
+	}
+	if !f.Until.IsZero() {
+		f.Until = f.Until.AddDate(0, 0, 1)
+	}
+	return f, nil
+}
+
+// writeMarkdown: one file per workspace in dir, or every document on stdout
+func writeMarkdown(ws []export.Workspace, dir string) error {
+	if dir == "" {
+		for i, w := range ws {
+			if i > 0 {
+				fmt.Print("\n---\n\n")
+			}
+			if err := export.WriteMarkdown(os.Stdout, w); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	used := map[string]bool{}
+	for _, w := range ws {
+		name := export.MarkdownFile(w)
+		// workspaces can share a name, the id tells their files apart
+		if used[name] {
+			name = strings.TrimSuffix(name, ".md") + "-" + w.Id[:min(8, len(w.Id))] + ".md"
+		}
+		used[name] = true
+		f, err := os.Create(path.Join(dir, name))
+		if err != nil {
+			return err
+		}
+		err = export.WriteMarkdown(f, w)
+		if err := errors.Join(err, f.Close()); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	fmt.Fprintf(os.Stderr, "exported %d workspace(s) to %s\n", len(ws), dir)
+	return nil
+}
+
+// exportCommand: writes the checkpoints in a format to share them or archive them with
+// a project
+func exportCommand(ctx context.Context, store db.Store, args []string) error {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	format := flags.String("format", export.FORMAT_MARKDOWN, "md, json or csv")
+	output := flags.String("o", "", "output file, a directory for md. stdout when empty")
+	workspaces := flags.String("workspace", "", "only these workspaces, comma separated names")
+	tag := flags.String("tag", "", "only workspaces with this tag")
+	since := flags.String("since", "", "only checkpoints from this day on, YYYY-MM-DD")
+	until := flags.String("until", "", "only checkpoints up to this day, YYYY-MM-DD")
+	flags.Parse(args)
+	f, err := exportFilter(*workspaces, *tag, *since, *until)
+	if err != nil {
+		return err
+	}
+	ws, err := export.Collect(ctx, store, f)
+	if err != nil {
+		return err
+	}
+	var write func(io.Writer, []export.Workspace) error
+	switch *format {
+	case export.FORMAT_MARKDOWN:
+		return writeMarkdown(ws, *output)
+	case export.FORMAT_JSON:
+		write = export.WriteJSON
+	case export.FORMAT_CSV:
+		write = export.WriteCSV
+	default:
+		return fmt.Errorf("unknown format '%s'", *format)
+	}
+	if *output == "" {
+		return write(os.Stdout, ws)
+	}
+	out, err := os.Create(*output)
+	if err != nil {
+		return err
+	}
+	return errors.Join(write(out, ws), out.Close())
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"workspaces-cli/models/db"
+	"workspaces-cli/models/export"
+	"workspaces-cli/pkg/theme"
+	"workspaces-cli/pkg/workspaces"
+)
+
+// importFormats: the format of an import file by extension, for single files and the
+// files of a directory alike
+var importFormats = map[string]string{
+	".json":     export.FORMAT_JSON,
+	".md":       export.FORMAT_MARKDOWN,
+	".markdown": export.FORMAT_MARKDOWN,
+}
+
+func importFormat(file string) string {
+	return importFormats[strings.ToLower(path.Ext(file))]
+}
+
+// readImport: the workspaces in a json export, a markdown document or a directory of them
+func readImport(file string) ([]export.Workspace, error) {
+	info, err := os.Stat(file)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		entries, err := os.ReadDir(file)
+		if err != nil {
+			return nil, err
+		}
+		ws := []export.Workspace{}
+		for _, e := range entries {
+			if e.IsDir() || importFormat(e.Name()) == "" {
+				continue
+			}
+			w, err := readImport(path.Join(file, e.Name()))
+			if err != nil {
+				return nil, err
+			}
+			ws = append(ws, w...)
+		}
+		return ws, nil
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	switch importFormat(file) {
+	case export.FORMAT_JSON:
+		ws, err := export.ReadJSON(f)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+		return ws, nil
+	case export.FORMAT_MARKDOWN:
+		w, err := export.ReadMarkdown(f, strings.TrimSuffix(path.Base(file), path.Ext(file)))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+		return []export.Workspace{w}, nil
+	}
+	return nil, fmt.Errorf("%s: not a .json, .md or .markdown file", file)
+}
+
+func printChange(c export.Change, dryrun bool) {
+	if c.Target == nil {
+		fmt.Println(theme.Render(theme.WARNING, fmt.Sprintf("skip %s: no workspace with its id, path or name", c.Source.Name)))
+		return
+	}
+	verb := "import"
+	if dryrun {
+		verb = "would import"
+	}
+	fmt.Printf("%s %d checkpoint(s) and %d tag(s) into %s (matched by %s), %d duplicate(s)\n",
+		verb, len(c.New), len(c.NewTags), c.Target.DirEntry.Name(), c.MatchedBy, c.Duplicates)
+	if !dryrun {
+		return
+	}
+	for _, cp := range c.New {
+		line, _, _ := strings.Cut(cp.Value, "\n")
+		fmt.Println(theme.Render(theme.SUCCESS, fmt.Sprintf("  + %s %s", cp.Date.Local().Format("2006-01-02 15:04"), line)))
+	}
+	for _, t := range c.NewTags {
+		fmt.Println(theme.Render(theme.SUCCESS, "  + tag "+t))
+	}
+}
+
+// importCommand: brings checkpoints in from exports or notes kept elsewhere
+func importCommand(ctx context.Context, store db.Store, local []workspaces.Workspace, args []string) error {
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	dryrun := flags.Bool("dry-run", false, "print what would change without changing it")
+	flags.Parse(args)
+	if flags.NArg() == 0 {
+		return errors.New("usage: import [-dry-run] <file or directory>...")
+	}
+	ws := []export.Workspace{}
+	for _, file := range flags.Args() {
+		w, err := readImport(file)
+		if err != nil {
+			return err
+		}
+		ws = append(ws, w...)
+	}
+	changes, err := export.Plan(ctx, store, local, ws)
+	if err != nil {
+		return err
+	}
+	for _, c := range changes {
+		printChange(c, *dryrun)
+	}
+	if *dryrun {
+		return nil
+	}
+	return export.Apply(ctx, store, changes)
+}
@@ -2,37 +2,176 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"fmt"
+	"log/slog"
 	"os"
+	"os/signal"
+	"path"
+	"syscall"
+	"time"
 	"workspaces-cli/models"
+	"workspaces-cli/models/db"
+	"workspaces-cli/pkg/config"
+	"workspaces-cli/pkg/debuglog"
 	"workspaces-cli/pkg/editors"
+	"workspaces-cli/pkg/theme"
 	"workspaces-cli/pkg/workspaces"
 
 	tea "github.com/charmbracelet/bubbletea"
 )
 
 func fatalf(format string, a ...any) {
-	fmt.Printf("\033[31m%s\033[0m", fmt.Errorf(format, a...))
+	fmt.Print(theme.Render(theme.ERROR, fmt.Errorf(format, a...).Error()))
 	os.Exit(1)
 }
 
+// setupDebugLog: the log is opt-in, through --debug or the environment
+func setupDebugLog(debug bool, format string) (func() error, error) {
+	if !debug && format == "" {
+		debuglog.Disable()
+		return func() error { return nil }, nil
+	}
+	dir, err := config.StateDir()
+	if err != nil {
+		return nil, err
+	}
+	f, err := debuglog.Setup(path.Join(dir, debuglog.LOG_FILE), format)
+	if err != nil {
+		return nil, err
+	}
+	return f.Close, nil
+}
+
+// backups: where the snapshots of the sqlite store go, the data dir unless backup_dir
+// says otherwise
+func backups(cfg config.Config) (db.Backups, error) {
+	dir := os.ExpandEnv(cfg.BackupDir)
+	if dir == "" {
+		d, err := config.DataDir()
+		if err != nil {
+			return db.Backups{}, err
+		}
+		dir = path.Join(d, "backups")
+	}
+	return db.Backups{Dir: dir, Keep: cfg.BackupKeep}, nil
+}
+
+// openStore: the backend the config asks for. the sqlite database lives next to the
+// workspaces, the files store in the data dir unless store_dir says otherwise.
+func openStore(ctx context.Context, cfg config.Config, root string) (db.Store, error) {
+	switch cfg.Store {
+	case "", db.STORE_SQLITE:
+		b, err := backups(cfg)
+		if err != nil {
+			return nil, err
+		}
+		s, err := db.OpenSQLite(ctx, path.Join(root, db.SQLITE_FILE), b)
+		if err != nil {
+			return nil, err
+		}
+		// the database is still usable without a fresh snapshot
+		if err := s.StartupBackup(ctx, b); err != nil {
+			slog.WarnContext(ctx, "startup backup", "error", err)
+			fmt.Fprintln(os.Stderr, theme.Render(theme.WARNING, fmt.Sprintf("startup backup: %s", err)))
+		}
+		return s, nil
+	case db.STORE_FILES:
+		dir := os.ExpandEnv(cfg.StoreDir)
+		if dir == "" {
+			d, err := config.DataDir()
+			if err != nil {
+				return nil, err
+			}
+			dir = path.Join(d, "store")
+		}
+		return db.OpenFiles(dir)
+	case db.STORE_MEMORY:
+		return db.NewMemory(), nil
+	}
+	return nil, fmt.Errorf("unknown store '%s'", cfg.Store)
+}
+
 func main() {
-	w, err := workspaces.Load(os.ExpandEnv("$HOME/development/workspaces"))
+	debug := flag.Bool("debug", false, fmt.Sprintf("write a debug log to the state dir, also enabled by %s", debuglog.ENV_VAR))
+	format := flag.String("debug-format", debuglog.FromEnv(), "format of the debug log, text or json")
+	flag.Parse()
+	closeLog, err := setupDebugLog(*debug, *format)
+	if err != nil {
+		fatalf("debug log: %w", err)
+	}
+	defer closeLog()
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+	cfgdir, err := config.Dir()
+	if err != nil {
+		fatalf("config dir: %w", err)
+	}
+	cfg, err := config.Load(path.Join(cfgdir, config.CONFIG_FILE))
+	if err != nil {
+		fatalf("load config: %w", err)
+	}
+	root := os.ExpandEnv("$HOME/development/workspaces")
+	start := time.Now()
+	w, err := workspaces.Load(root)
 	if err != nil {
 		fatalf("load workspaces: %w", err)
 	}
+	slog.Debug("loaded workspaces", "root", root, "count", len(w), "duration", time.Since(start))
+	switch flag.Arg(0) {
+	case "", "doctor", "export", "import", "sync":
+	case "db":
+		// restore replaces the database file, it can't be open
+		if err := dbCommand(ctx, cfg, root, flag.Args()[1:]); err != nil {
+			fatalf("db: %w", err)
+		}
+		return
+	default:
+		fatalf("unknown command '%s'", flag.Arg(0))
+	}
+	store, err := openStore(ctx, cfg, root)
+	if err != nil {
+		fatalf("open store: %w", err)
+	}
+	if flag.Arg(0) != "" {
+		switch flag.Arg(0) {
+		case "doctor":
+			err = doctor(ctx, w, store, flag.Args()[1:])
+		case "export":
+			err = exportCommand(ctx, store, flag.Args()[1:])
+		case "import":
+			err = importCommand(ctx, store, w, flag.Args()[1:])
+		case "sync":
+			err = syncCommand(ctx, cfg, store, w)
+		}
+		store.Close()
+		if err != nil {
+			fatalf("%s: %w", flag.Arg(0), err)
+		}
+		return
+	}
 	m, err := models.NewModel(
-		context.Background(),
+		ctx,
+		root,
 		w,
-		os.ExpandEnv("$HOME/development/workspaces/workspaces.sql"),
-		editors.Helix{})
+		store,
+		editors.Helix{},
+		cfg)
 	if err != nil {
+		store.Close()
 		fatalf("new model: %w", err)
 	}
 	defer m.Cleanup()
-	p := tea.NewProgram(m)
-	if _, err := p.Run(); err != nil {
+	opts := []tea.ProgramOption{tea.WithContext(ctx)}
+	if !cfg.DisableMouse {
+		opts = append(opts, tea.WithMouseCellMotion())
+	}
+	p := tea.NewProgram(m, opts...)
+	_, err = p.Run()
+	// stops the commands and queries the ui left running
+	cancel()
+	if err != nil && !(errors.Is(err, tea.ErrProgramKilled) && ctx.Err() != nil) {
 		fatalf("run error: %w", err)
-		os.Exit(1)
 	}
 }
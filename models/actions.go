@@ -0,0 +1,369 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"workspaces-cli/models/modes"
+	"workspaces-cli/pkg/config"
+	"workspaces-cli/pkg/keymap"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// action: named operation of a mode, triggered through the keymap. in default mode the
+// registered commands and the user commands are actions as well.
+type action struct {
+	name        string
+	description string
+	handler     func(m *Application, ctx context.Context) tea.Cmd
+}
+
+var (
+	actionRegistry map[modes.InputMode][]action = map[modes.InputMode][]action{}
+)
+
+func registerAction(mode modes.InputMode, a action) {
+	if slices.ContainsFunc(actionRegistry[mode], func(r action) bool { return r.name == a.name }) {
+		panic(fmt.Sprintf("action registered twice: '%s' (%s)", a.name, modes.Name(mode)))
+	}
+	actionRegistry[mode] = append(actionRegistry[mode], a)
+}
+
+func init() {
+	registerAction(modes.DEFAULT, action{"cursor_up", "move the cursor up", func(m *Application, ctx context.Context) tea.Cmd {
+		m.cursorUp()
+		return tea.Batch(m.defaultRenderer, m.detailLoader(ctx))
+	}})
+	registerAction(modes.DEFAULT, action{"cursor_down", "move the cursor down", func(m *Application, ctx context.Context) tea.Cmd {
+		m.cursorDown()
+		return tea.Batch(m.defaultRenderer, m.detailLoader(ctx))
+	}})
+	registerAction(modes.DEFAULT, action{"top", "jump to the first workspace", func(m *Application, ctx context.Context) tea.Cmd {
+		m.cursor = 0
+		return tea.Batch(m.defaultRenderer, m.detailLoader(ctx))
+	}})
+	registerAction(modes.DEFAULT, action{"bottom", "jump to the last workspace", func(m *Application, ctx context.Context) tea.Cmd {
+		m.cursor = m.getCursorMax()
+		return tea.Batch(m.defaultRenderer, m.detailLoader(ctx))
+	}})
+	registerAction(modes.DEFAULT, action{"half_page_down", "move the cursor down half a page", func(m *Application, ctx context.Context) tea.Cmd {
+		m.cursor = min(m.cursor+max(m.maxrows/2, 1), m.getCursorMax())
+		return tea.Batch(m.defaultRenderer, m.detailLoader(ctx))
+	}})
+	registerAction(modes.DEFAULT, action{"half_page_up", "move the cursor up half a page", func(m *Application, ctx context.Context) tea.Cmd {
+		m.cursor = max(m.cursor-max(m.maxrows/2, 1), 0)
+		return tea.Batch(m.defaultRenderer, m.detailLoader(ctx))
+	}})
+	registerAction(modes.DEFAULT, action{"page_down", "move the cursor down a page", func(m *Application, ctx context.Context) tea.Cmd {
+		m.cursor = min(m.cursor+m.maxrows, m.getCursorMax())
+		return tea.Batch(m.defaultRenderer, m.detailLoader(ctx))
+	}})
+	registerAction(modes.DEFAULT, action{"page_up", "move the cursor up a page", func(m *Application, ctx context.Context) tea.Cmd {
+		m.cursor = max(m.cursor-m.maxrows, 0)
+		return tea.Batch(m.defaultRenderer, m.detailLoader(ctx))
+	}})
+	registerAction(modes.DEFAULT, action{"close_output", "close the command output", func(m *Application, ctx context.Context) tea.Cmd {
+		m.outputPane = ""
+		return m.defaultRenderer
+	}})
+	registerAction(modes.DEFAULT, action{"filter", "filter workspaces by name or date", func(m *Application, ctx context.Context) tea.Cmd {
+		m.startMode(modes.FILTER)
+		return m.filterRenderer
+	}})
+	registerAction(modes.DEFAULT, action{"command_palette", "open the command palette", func(m *Application, ctx context.Context) tea.Cmd {
+		m.startMode(modes.SELECT_COMMAND)
+		return m.commandSelectRenderer
+	}})
+	registerAction(modes.DEFAULT, action{"help", "show every action and its keys", func(m *Application, ctx context.Context) tea.Cmd {
+		m.startMode(modes.HELP)
+		return m.helpRenderer
+	}})
+	registerAction(modes.DEFAULT, action{"dismiss", "dismiss the notifications", func(m *Application, ctx context.Context) tea.Cmd {
+		m.dismissToasts()
+		return m.defaultRenderer
+	}})
+	registerAction(modes.DEFAULT, action{"message_log", "show every message of the session", func(m *Application, ctx context.Context) tea.Cmd {
+		m.startMode(modes.MESSAGE_LOG)
+		return m.messageLogRenderer
+	}})
+	registerAction(modes.DEFAULT, action{"quit", "quit", func(m *Application, ctx context.Context) tea.Cmd {
+		return tea.Quit
+	}})
+
+	registerAction(modes.FILTER, action{"cancel", "leave filter mode", func(m *Application, ctx context.Context) tea.Cmd {
+		m.resetMode()
+		return tea.Batch(m.defaultRenderer, m.detailLoader(ctx))
+	}})
+	registerAction(modes.FILTER, action{"accept", "select the workspace and leave filter mode", func(m *Application, ctx context.Context) tea.Cmd {
+		// keep selected item in filter mode over to default mode
+		if w := m.selectedWorkspace(); w != nil {
+			for m.cursor = 0; m.cursor < len(m.workspaces) && &m.workspaces[m.cursor] != w; m.cursor++ {
+			}
+		}
+		m.resetMode()
+		return tea.Batch(m.defaultRenderer, m.detailLoader(ctx))
+	}})
+	registerAction(modes.FILTER, action{"cursor_up", "move the cursor up", func(m *Application, ctx context.Context) tea.Cmd {
+		m.filterCursorUp()
+		return tea.Batch(m.filterRenderer, m.detailLoader(ctx))
+	}})
+	registerAction(modes.FILTER, action{"cursor_down", "move the cursor down", func(m *Application, ctx context.Context) tea.Cmd {
+		m.filterCursorDown()
+		return tea.Batch(m.filterRenderer, m.detailLoader(ctx))
+	}})
+	registerAction(modes.FILTER, action{"top", "jump to the first match", func(m *Application, ctx context.Context) tea.Cmd {
+		m.filterCursor = 0
+		return tea.Batch(m.filterRenderer, m.detailLoader(ctx))
+	}})
+	registerAction(modes.FILTER, action{"bottom", "jump to the last match", func(m *Application, ctx context.Context) tea.Cmd {
+		m.filterCursor = m.getFilterCursorMax()
+		return tea.Batch(m.filterRenderer, m.detailLoader(ctx))
+	}})
+	registerAction(modes.FILTER, action{"page_down", "move the cursor down a page", func(m *Application, ctx context.Context) tea.Cmd {
+		m.filterCursor = min(m.filterCursor+m.maxrows, m.getFilterCursorMax())
+		return tea.Batch(m.filterRenderer, m.detailLoader(ctx))
+	}})
+	registerAction(modes.FILTER, action{"page_up", "move the cursor up a page", func(m *Application, ctx context.Context) tea.Cmd {
+		m.filterCursor = max(m.filterCursor-m.maxrows, 0)
+		return tea.Batch(m.filterRenderer, m.detailLoader(ctx))
+	}})
+	registerAction(modes.FILTER, action{"delete_char", "delete the last filter character", func(m *Application, ctx context.Context) tea.Cmd {
+		if l := len(m.filterValue); l > 0 {
+			m.filterValue = m.filterValue[:l-1]
+		}
+		m.filterCursor = 0
+		m.applyFilter()
+		return tea.Batch(m.filterRenderer, m.detailLoader(ctx))
+	}})
+
+	registerAction(modes.SELECT_COMMAND, action{"cancel", "close the command palette", func(m *Application, ctx context.Context) tea.Cmd {
+		m.resetMode()
+		return m.defaultRenderer
+	}})
+	registerAction(modes.SELECT_COMMAND, action{"execute", "run the selected command", func(m *Application, ctx context.Context) tea.Cmd {
+		return m.activeCommandHandler(ctx)
+	}})
+	registerAction(modes.SELECT_COMMAND, action{"cursor_up", "move the cursor up", func(m *Application, ctx context.Context) tea.Cmd {
+		m.commandCursorUp()
+		return m.commandSelectRenderer
+	}})
+	registerAction(modes.SELECT_COMMAND, action{"cursor_down", "move the cursor down", func(m *Application, ctx context.Context) tea.Cmd {
+		m.commandCursorDown()
+		return m.commandSelectRenderer
+	}})
+	registerAction(modes.SELECT_COMMAND, action{"delete_char", "delete the last palette character", func(m *Application, ctx context.Context) tea.Cmd {
+		if l := len(m.commandFilterValue); l > 0 {
+			m.commandFilterValue = m.commandFilterValue[:l-1]
+		}
+		m.commandCursor = 0
+		m.applyCommandFilter()
+		return m.commandSelectRenderer
+	}})
+
+	registerAction(modes.HELP, action{"close", "close the help", func(m *Application, ctx context.Context) tea.Cmd {
+		m.resetMode()
+		return m.defaultRenderer
+	}})
+
+	registerAction(modes.MESSAGE_LOG, action{"close", "close the message log", func(m *Application, ctx context.Context) tea.Cmd {
+		m.resetMode()
+		return m.defaultRenderer
+	}})
+	registerAction(modes.MESSAGE_LOG, action{"scroll_up", "scroll up", func(m *Application, ctx context.Context) tea.Cmd {
+		m.scrollMessageLog(-1)
+		return m.messageLogRenderer
+	}})
+	registerAction(modes.MESSAGE_LOG, action{"scroll_down", "scroll down", func(m *Application, ctx context.Context) tea.Cmd {
+		m.scrollMessageLog(1)
+		return m.messageLogRenderer
+	}})
+	registerAction(modes.MESSAGE_LOG, action{"page_up", "scroll up a page", func(m *Application, ctx context.Context) tea.Cmd {
+		m.scrollMessageLog(-m.maxrows)
+		return m.messageLogRenderer
+	}})
+	registerAction(modes.MESSAGE_LOG, action{"page_down", "scroll down a page", func(m *Application, ctx context.Context) tea.Cmd {
+		m.scrollMessageLog(m.maxrows)
+		return m.messageLogRenderer
+	}})
+}
+
+func defaultBindings() map[string][]keymap.Binding {
+	return map[string][]keymap.Binding{
+		modes.Name(modes.DEFAULT): {
+			{Keys: "up", Action: "cursor_up"},
+			{Keys: "k", Action: "cursor_up"},
+			{Keys: "down", Action: "cursor_down"},
+			{Keys: "j", Action: "cursor_down"},
+			{Keys: "g g", Action: "top"},
+			{Keys: "home", Action: "top"},
+			{Keys: "G", Action: "bottom"},
+			{Keys: "end", Action: "bottom"},
+			{Keys: "pgdown", Action: "page_down"},
+			{Keys: "pgup", Action: "page_up"},
+			{Keys: "ctrl+d", Action: "half_page_down"},
+			{Keys: "ctrl+u", Action: "half_page_up"},
+			{Keys: "x", Action: "close_output"},
+			{Keys: "/", Action: "filter"},
+			{Keys: ":", Action: "command_palette"},
+			{Keys: "?", Action: "help"},
+			{Keys: "esc", Action: "dismiss"},
+			{Keys: "L", Action: "message_log"},
+			{Keys: "q", Action: "quit"},
+			{Keys: "c", Action: "copy_menu"},
+			{Keys: "space", Action: "toggle_mark"},
+			{Keys: "A", Action: "mark_all"},
+			{Keys: "I", Action: "invert_marks"},
+			{Keys: "U", Action: "clear_marks"},
+			{Keys: "t", Action: "tag"},
+			{Keys: "o", Action: "open_workspace"},
+			{Keys: "d", Action: "toggle_details"},
+		},
+		modes.Name(modes.FILTER): {
+			{Keys: "esc", Action: "cancel"},
+			{Keys: "enter", Action: "accept"},
+			{Keys: "up", Action: "cursor_up"},
+			{Keys: "ctrl+p", Action: "cursor_up"},
+			{Keys: "down", Action: "cursor_down"},
+			{Keys: "ctrl+n", Action: "cursor_down"},
+			{Keys: "home", Action: "top"},
+			{Keys: "end", Action: "bottom"},
+			{Keys: "pgdown", Action: "page_down"},
+			{Keys: "pgup", Action: "page_up"},
+			{Keys: "backspace", Action: "delete_char"},
+			{Keys: "tab", Action: "toggle_mark"},
+			{Keys: "ctrl+a", Action: "mark_all"},
+			{Keys: "ctrl+r", Action: "invert_marks"},
+		},
+		modes.Name(modes.SELECT_COMMAND): {
+			{Keys: "esc", Action: "cancel"},
+			{Keys: "enter", Action: "execute"},
+			{Keys: "up", Action: "cursor_up"},
+			{Keys: "ctrl+p", Action: "cursor_up"},
+			{Keys: "down", Action: "cursor_down"},
+			{Keys: "ctrl+n", Action: "cursor_down"},
+			{Keys: "backspace", Action: "delete_char"},
+		},
+		modes.Name(modes.HELP): {
+			{Keys: "esc", Action: "close"},
+			{Keys: "q", Action: "close"},
+			{Keys: "?", Action: "close"},
+		},
+		modes.Name(modes.COPY): {
+			{Keys: "esc", Action: "cancel"},
+			{Keys: "enter", Action: "execute"},
+			{Keys: "up", Action: "cursor_up"},
+			{Keys: "k", Action: "cursor_up"},
+			{Keys: "down", Action: "cursor_down"},
+			{Keys: "j", Action: "cursor_down"},
+			{Keys: "p", Action: "path"},
+			{Keys: "n", Action: "name"},
+			{Keys: "c", Action: "cd"},
+			{Keys: "r", Action: "remote"},
+			{Keys: "b", Action: "branch"},
+			{Keys: "K", Action: "checkpoint"},
+			{Keys: "m", Action: "summary"},
+		},
+		modes.Name(modes.DOCTOR): {
+			{Keys: "esc", Action: "close"},
+			{Keys: "q", Action: "close"},
+			{Keys: "up", Action: "cursor_up"},
+			{Keys: "k", Action: "cursor_up"},
+			{Keys: "down", Action: "cursor_down"},
+			{Keys: "j", Action: "cursor_down"},
+			{Keys: "enter", Action: "fix"},
+			{Keys: "f", Action: "fix"},
+			{Keys: "F", Action: "fix_all"},
+			{Keys: "x", Action: "purge"},
+		},
+		modes.Name(modes.PROMPT): {
+			{Keys: "esc", Action: "cancel"},
+			{Keys: "enter", Action: "accept"},
+			{Keys: "backspace", Action: "delete_char"},
+		},
+		modes.Name(modes.MESSAGE_LOG): {
+			{Keys: "esc", Action: "close"},
+			{Keys: "q", Action: "close"},
+			{Keys: "L", Action: "close"},
+			{Keys: "up", Action: "scroll_up"},
+			{Keys: "k", Action: "scroll_up"},
+			{Keys: "down", Action: "scroll_down"},
+			{Keys: "j", Action: "scroll_down"},
+			{Keys: "pgup", Action: "page_up"},
+			{Keys: "pgdown", Action: "page_down"},
+		},
+	}
+}
+
+// knownActions: action names by mode name, for validating the keymap
+func knownActions(cfg config.Config) map[string][]string {
+	known := map[string][]string{}
+	for mode, actions := range actionRegistry {
+		for i := range actions {
+			known[modes.Name(mode)] = append(known[modes.Name(mode)], actions[i].name)
+		}
+	}
+	for i := range commandRegistry {
+		known[modes.Name(modes.DEFAULT)] = append(known[modes.Name(modes.DEFAULT)], commandRegistry[i].name)
+	}
+	for i := range cfg.Commands {
+		known[modes.Name(modes.DEFAULT)] = append(known[modes.Name(modes.DEFAULT)], cfg.Commands[i].Name)
+	}
+	return known
+}
+
+// isBuiltinName: names of default mode actions and registered commands, runAction finds
+// them before any user command of the same name
+func isBuiltinName(name string) bool {
+	return slices.ContainsFunc(actionRegistry[modes.DEFAULT], func(a action) bool { return a.name == name }) ||
+		slices.ContainsFunc(commandRegistry, func(c command) bool { return c.name == name })
+}
+
+// validateCommands: user commands named like a builtin would never run
+func validateCommands(cmds []config.Command) error {
+	errs := []error{}
+	for i := range cmds {
+		if isBuiltinName(cmds[i].Name) {
+			errs = append(errs, fmt.Errorf("'%s' is the name of a builtin action", cmds[i].Name))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// newKeymap: default bindings with the user bindings on top. conflicting or dangling
+// bindings fail here rather than silently doing nothing at runtime.
+func newKeymap(cfg config.Config) (*keymap.Keymap, error) {
+	k := keymap.New(defaultBindings(), cfg.Keybindings)
+	if err := k.Validate(knownActions(cfg)); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// runAction: actions of the mode first, then in default mode the registered commands
+// and the user commands
+func (m *Application) runAction(ctx context.Context, mode modes.InputMode, name string) tea.Cmd {
+	if i := slices.IndexFunc(actionRegistry[mode], func(a action) bool { return a.name == name }); i >= 0 {
+		return actionRegistry[mode][i].handler(m, ctx)
+	}
+	if mode != modes.DEFAULT {
+		return nil
+	}
+	if i := slices.IndexFunc(commandRegistry, func(c command) bool { return c.name == name }); i >= 0 {
+		if c := commandRegistry[i]; c.isAvailable(m) {
+			return c.handler(m, ctx)
+		}
+		return nil
+	}
+	if i := slices.IndexFunc(m.config.Commands, func(c config.Command) bool { return c.Name == name }); i >= 0 {
+		if c := userCommand(m.config.Commands[i]); c.isAvailable(m) {
+			return c.handler(m, ctx)
+		}
+	}
+	return nil
+}
+
+func (m *Application) helpRenderer() tea.Msg {
+	return renderpanescmd{main: m.generateHelpString(), footer: m.generateFooter()}
+}
@@ -0,0 +1,14 @@
+package models
+
+import (
+	"context"
+	"log/slog"
+	"workspaces-cli/pkg/workspaces"
+)
+
+// recordActivity: best effort, the action already happened whether the store keeps up or not
+func (m *Application) recordActivity(ctx context.Context, w workspaces.Workspace, kind string) {
+	if err := m.store.RecordActivity(ctx, w, kind); err != nil {
+		slog.WarnContext(ctx, "record activity", "workspace", w.Path(), "kind", kind, "error", err)
+	}
+}
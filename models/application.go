@@ -4,54 +4,85 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"os"
-	"os/exec"
+	"log/slog"
 	"strings"
 	"time"
 	"workspaces-cli/models/db"
 	"workspaces-cli/models/modes"
+	"workspaces-cli/pkg/clipboard"
+	"workspaces-cli/pkg/config"
 	"workspaces-cli/pkg/editors"
-	"workspaces-cli/pkg/textcolor"
+	"workspaces-cli/pkg/keymap"
+	"workspaces-cli/pkg/theme"
 	"workspaces-cli/pkg/workspaces"
 
 	tea "github.com/charmbracelet/bubbletea"
-	"golang.design/x/clipboard"
 )
 
 type Application struct {
+	ctx  context.Context // cancelled when the program shuts down, ends whatever still runs
 	mode modes.InputMode // user input mode. determines what's rendered and how input is handled
 
-	editor editors.Editor
-	// TODO: mainPane needs to enforce persistent height throughout execution to prevent ghosting
-	mainPane   string // main pane display
-	footerPane string // footer display
+	editor     editors.Editor
+	store      db.Store // workspaces, checkpoints, tags and activity
+	mainPane   string   // main pane display
+	footerPane string   // footer display
+	detailPane string   // selected workspace details, rendered between main and footer
+
+	// detail pane fields
+	isDetailActive bool
+	details        map[string]detail // rendered details by workspace path
+	detailRows     int               // height of the bottom pane, a share of the terminal
+	detailLoad     detailLoad        // the load in the background, at most one at a time
+
+	// terminal size, zero until the first tea.WindowSizeMsg
+	width     int
+	height    int
+	lastClick click
 
 	// workspace fields
 	workspaces []workspaces.Workspace
 	maxnamelen int
 	cursor     int
 	maxrows    int
+	listView   viewport
 
 	// filter mode fields
 	filterCursor       int
+	filterView         viewport
 	filterValue        string
 	isFilterActive     bool
 	filteredWorkspaces []*workspaces.Workspace
 
+	keymap     *keymap.Keymap
+	clipboard  clipboard.Clipboard
+	copyCursor int             // selected format of the copy menu
+	marked     map[string]bool // paths of the workspaces batch actions run on
+	watcher    *workspaces.Watcher
+
+	// doctor fields
+	problems     []db.Problem // nil while checking
+	doctorCursor int
+	doctorPurge  string // workspace the purge was asked for once, it only runs when asked again
+	doctorRelink string // workspace the relink by name was asked for once, the same
+	prompt       prompt
+
 	// command mode fields
-	commands      []string
-	commandCursor int
-}
+	config             config.Config
+	commands           []command // available commands
+	paletteCommands    []command // available commands matching the palette input
+	commandCursor      int
+	commandFilterValue string
+	outputPane         string // output of background commands, rendered below the main pane
 
-func (m *Application) increaseMaxRows() {
-	if m.maxrows < len(m.workspaces)-1 {
-		m.maxrows++
-	}
-}
-func (m *Application) decreaseMaxRows() {
-	if m.maxrows > 1 {
-		m.maxrows--
-	}
+	// notification fields
+	toasts   []notification // shown until dismissed
+	messages []notification // every notification of the session
+	logView  viewport
+
+	// status line fields
+	statusQueue []status // the head is shown, the rest waits for it to expire
+	statusSeq   int
 }
 
 func (m *Application) resetMode() {
@@ -63,7 +94,17 @@ func (m *Application) resetMode() {
 		clear(m.filteredWorkspaces)
 	case modes.SELECT_COMMAND:
 		m.commandCursor = 0
+		m.commandFilterValue = ""
+	case modes.COPY:
+		m.copyCursor = 0
+	case modes.DOCTOR:
+		m.problems = nil
+		m.doctorCursor = 0
+		m.doctorPurge, m.doctorRelink = "", ""
+	case modes.PROMPT:
+		m.prompt = prompt{}
 	}
+	m.keymap.Reset()
 	m.mode = modes.DEFAULT
 }
 
@@ -73,15 +114,35 @@ func (m *Application) startMode(mode modes.InputMode) {
 		m.filterCursor = 0
 		m.filterValue = ""
 		m.isFilterActive = true
+		m.applyFilter()
 	case modes.SELECT_COMMAND:
 		m.commandCursor = 0
+		m.commandFilterValue = ""
+		m.loadCommands()
+		m.applyCommandFilter()
+	case modes.HELP:
+		m.loadCommands()
+	case modes.MESSAGE_LOG:
+		m.scrollMessageLog(len(m.messages))
+	case modes.COPY:
+		m.copyCursor = 0
 	}
+	m.keymap.Reset()
 	m.mode = mode
 }
 
+func (m *Application) applyFilter() {
+	m.filteredWorkspaces = make([]*workspaces.Workspace, 0, len(m.workspaces))
+	for i := range m.workspaces {
+		if isFuzzyWorkspaceMatch(&m.workspaces[i], m.filterValue) {
+			m.filteredWorkspaces = append(m.filteredWorkspaces, &m.workspaces[i])
+		}
+	}
+}
+
 func (m *Application) getCommandCursorMax() int {
-	if len(m.commands) > 0 {
-		return len(m.commands) - 1
+	if len(m.paletteCommands) > 0 {
+		return len(m.paletteCommands) - 1
 	}
 	return 0
 }
@@ -166,34 +227,42 @@ func (m *Application) generateWorkspaceString(pos, namepadding int, selected boo
 	)
 	if selected {
 		cursor = "👉"
-		name = fmt.Sprintf("\033[1;34m%-*s\033[0m", namepadding, w.DirEntry.Name())
-		path = fmt.Sprintf("\033[90m%s\033[0m", w.Path())
-		index = fmt.Sprintf("\033[1;34m%-3d\033[0m", pos)
+		name = theme.Render(theme.SELECTED, fmt.Sprintf("%-*s", namepadding, w.TruncatedName(namepadding, TRUNCATE_MARKER)))
+		path = w.Path()
+		if width := m.pathWidth(namepadding); width >= 0 {
+			path = truncateLeft(path, width)
+		}
+		path = theme.Render(theme.PATH, path)
+		index = theme.Render(theme.SELECTED_INDEX, fmt.Sprintf("%-*d", INDEX_WIDTH, pos))
 	} else {
 		cursor = "  "
-		name = fmt.Sprintf("%-*s", namepadding, w.DirEntry.Name())
-		index = fmt.Sprintf("\033[00m%-3d\033[0m", pos)
+		name = theme.Render(theme.NAME, fmt.Sprintf("%-*s", namepadding, w.TruncatedName(namepadding, TRUNCATE_MARKER)))
+		index = theme.Render(theme.INDEX, fmt.Sprintf("%-*d", INDEX_WIDTH, pos))
+	}
+	mark := " "
+	if m.isMarked(w) {
+		mark = theme.Render(theme.MARKED, "●")
 	}
-	return fmt.Sprintf("%s   %s   %s   %s   %s", cursor, index, name, modtime, path)
+	return fmt.Sprintf(ROW_FORMAT, cursor, mark, index, name, modtime, path)
 }
 
 func (m *Application) generateFooter() string {
 	b := strings.Builder{}
 	switch m.mode {
 	case modes.SELECT_COMMAND:
-		for i := range m.commands {
-			if m.commandCursor == i {
-				b.WriteString(" > " + m.commands[i] + "\n")
-			} else {
-				b.WriteString("   " + m.commands[i] + "\n")
-			}
-		}
+		b.WriteString(m.generatePaletteString())
+		b.WriteString(m.generateFooterHints())
+	case modes.COPY:
+		b.WriteString(m.generateCopyMenuString())
+		b.WriteString(m.generateFooterHints())
+	case modes.PROMPT:
+		b.WriteString(m.generatePromptString())
+		b.WriteString(m.generateFooterHints())
 	case modes.FILTER:
-		b.WriteString(textcolor.Colorize(textcolor.YELLOW, fmt.Sprintf("↳ FILTER > %s", m.filterValue)) + "\n")
+		b.WriteString(theme.Render(theme.PROMPT, fmt.Sprintf("↳ FILTER > %s", m.filterValue)) + "\n")
 		fallthrough
 	default:
-		b.WriteString(textcolor.Colorize(textcolor.LIGHT_GRAY, "   type 'c' to copy selected path to clipboard\n"))
-		b.WriteString(textcolor.Colorize(textcolor.LIGHT_GRAY, "   type 'o' to open selected workspace in vscode\n"))
+		b.WriteString(m.generateFooterHints())
 	}
 	return b.String()
 }
@@ -206,12 +275,6 @@ func (m *Application) generateCommandSelectString() string {
 }
 
 func (m *Application) generateFilterWorkspacesString() string {
-	m.filteredWorkspaces = make([]*workspaces.Workspace, 0, len(m.workspaces))
-	for i := range m.workspaces {
-		if isFuzzyWorkspaceMatch(&m.workspaces[i], m.filterValue) {
-			m.filteredWorkspaces = append(m.filteredWorkspaces, &m.workspaces[i])
-		}
-	}
 	strs := make([]func(int) string, len(m.filteredWorkspaces))
 	for i := range m.filteredWorkspaces {
 		if l := len(m.filteredWorkspaces[i].DirEntry.Name()); l > m.maxnamelen {
@@ -224,8 +287,8 @@ func (m *Application) generateFilterWorkspacesString() string {
 	}
 	b := strings.Builder{}
 	for i := range m.maxrows {
-		if i < len(strs) {
-			b.WriteString(strs[i](m.maxnamelen) + "\n")
+		if i+m.filterView.offset < len(strs) {
+			b.WriteString(strs[i+m.filterView.offset](m.namePadding()) + "\n")
 		} else {
 			b.WriteString(".\n")
 		}
@@ -247,8 +310,8 @@ func (m *Application) generateWorkspacesString() string {
 	}
 	b := strings.Builder{}
 	for i := range m.maxrows {
-		if i+m.cursor < len(strs) {
-			b.WriteString(strs[i+m.cursor](m.maxnamelen) + "\n")
+		if i+m.listView.offset < len(strs) {
+			b.WriteString(strs[i+m.listView.offset](m.namePadding()) + "\n")
 		} else {
 			b.WriteString(".\n")
 		}
@@ -257,180 +320,61 @@ func (m *Application) generateWorkspacesString() string {
 }
 
 func (m *Application) activeCommandHandler(ctx context.Context) tea.Cmd {
-	switch m.commandCursor {
-	case 0:
-		f, err := m.editor.CreateTemp()
-		if err != nil {
-			return func() tea.Msg { return errormessage{err} }
-		}
-		c := exec.Command(m.editor.Command(), m.editor.OpenFileArgs(f.Name())...)
-		return tea.ExecProcess(c, func(err error) tea.Msg {
-			// here we need to load the data and render it
-			// then we'll need to ship it to the db
-			if err != nil {
-				return errormessage{err: fmt.Errorf("exec '%s': %w", m.editor.Command(), err)}
-			}
-			data, err := os.ReadFile(f.Name())
-			if err != nil {
-				return errormessage{fmt.Errorf("read file: %w", err)}
-			}
-			if len(data) == 0 {
-				return renderpaneswithcallbackcmd{
-					renderpanescmd: renderpanescmd{
-						main:   "      ❎ no checkpoint data received",
-						footer: m.generateFooter()},
-					callback: func() tea.Msg {
-						m.resetMode()
-						time.Sleep(MESSAGE_TIMEOUT)
-						return m.defaultRenderer()
-					},
-				}
-			}
-			if err := db.InsertCheckpoint(ctx, m.workspaces[m.cursor], data); err != nil {
-				return errormessage{err}
-			}
-			// now we generate the checkpoint row and insert it to the db
-			// the returned data will be the the result of the write op
-			return renderpaneswithcallbackcmd{
-				renderpanescmd: renderpanescmd{
-					main:   "      ✅ checkpoint inserted",
-					footer: m.generateFooter()},
-				callback: func() tea.Msg {
-					m.resetMode()
-					time.Sleep(MESSAGE_TIMEOUT)
-					return m.defaultRenderer()
-				},
-			}
-		})
-	case 1:
-		return func() tea.Msg { return viewcheckpointscmd("mock message from view checkpoints") }
-	default:
-		return nil
+	if m.commandCursor < len(m.paletteCommands) {
+		c := m.paletteCommands[m.commandCursor]
+		m.resetMode()
+		return c.handler(m, ctx)
 	}
+	return nil
+}
+
+// resolveKey: feeds the key to the keymap and runs the action it completes. handled is
+// false for keys that are neither bound nor part of a pending sequence.
+func (m *Application) resolveKey(ctx context.Context, key tea.KeyMsg) (tea.Cmd, bool) {
+	name, pending := m.keymap.Resolve(modes.Name(m.mode), key.String())
+	slog.DebugContext(ctx, "key", "mode", modes.Name(m.mode), "key", key.String(), "action", name, "pending", pending)
+	if pending {
+		return nil, true
+	}
+	if name == "" {
+		return nil, false
+	}
+	return m.runAction(ctx, m.mode, name), true
 }
 
 func (m *Application) commandMode_handleKeyMsg(ctx context.Context, key tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if cmd, ok := m.resolveKey(ctx, key); ok {
+		return m, cmd
+	}
 	switch key.Type {
-	case tea.KeyEsc:
-		m.resetMode()
-		return m, m.defaultRenderer
-	case tea.KeyEnter:
-		// to keep things uniform, we need the command to have a renderer function (tea.Cmd)
-		return m, m.activeCommandHandler(ctx)
-		// show data in the main pane
-	case tea.KeyDown:
-		m.commandCursorDown()
-		return m, m.commandSelectRenderer
-	case tea.KeyUp:
-		m.commandCursorUp()
+	case tea.KeyRunes, tea.KeySpace:
+		m.commandFilterValue += key.String()
+		m.commandCursor = 0
+		m.applyCommandFilter()
 		return m, m.commandSelectRenderer
 	}
 	return m, nil
 }
 
-func (m *Application) filterMode_handleKeyMsg(key tea.KeyMsg) (tea.Model, tea.Cmd) {
+func (m *Application) filterMode_handleKeyMsg(ctx context.Context, key tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if cmd, ok := m.resolveKey(ctx, key); ok {
+		return m, cmd
+	}
 	switch key.Type {
-	case tea.KeyEsc:
-		m.resetMode()
-		return m, m.defaultRenderer
-	case tea.KeyEnter:
-		// keep selected item in filter mode over to default mode
-		for m.cursor = 0; m.cursor < len(m.workspaces) && &m.workspaces[m.cursor] != m.filteredWorkspaces[m.filterCursor]; m.cursor++ {
-		}
-		m.resetMode()
-		return m, m.defaultRenderer
-	case tea.KeyBackspace:
-		if l := len(m.filterValue); l > 0 {
-			m.filterValue = m.filterValue[:l-1]
-		}
-		m.filterCursor = 0
-		return m, m.filterRenderer
-	case tea.KeyUp:
-		m.filterCursorUp()
-		return m, m.filterRenderer
-	case tea.KeyDown:
-		m.filterCursorDown()
-		return m, m.filterRenderer
 	case tea.KeyRunes:
 		m.filterValue += key.String()
 		m.filterCursor = 0
-		return m, m.filterRenderer
+		m.applyFilter()
+		return m, tea.Batch(m.filterRenderer, m.detailLoader(ctx))
 	}
 	return m, nil
 }
 
-func (m *Application) defaultMode_handleKeyMsg(key tea.KeyMsg) (tea.Model, tea.Cmd) {
-	// nav keys
-	switch key.Type {
-	case tea.KeyUp:
-		m.cursorUp()
-		return m, m.defaultRenderer
-	case tea.KeyDown:
-		m.cursorDown()
-		return m, m.defaultRenderer
-	}
-	// action keys
-	switch key.String() {
-	case "q":
-		return m, tea.Quit
-	case "+":
-		m.increaseMaxRows()
-		return m, m.defaultRenderer
-	case "-":
-		m.decreaseMaxRows()
-		return m, m.defaultRenderer
-	case "c": // clip workspace path
-		return m, func() tea.Msg {
-			b := strings.Builder{}
-			for range m.maxrows {
-				b.WriteString("\n")
-			}
-			b.WriteString("📋 copied message to clipboard")
-			return renderpaneswithcallbackcmd{
-				renderpanescmd: renderpanescmd{
-					main:   b.String(),
-					footer: m.generateFooter(),
-				},
-				callback: func() tea.Msg {
-					clipboard.Write(clipboard.FmtText, []byte(m.workspaces[m.cursor].Path()))
-					time.Sleep(MESSAGE_TIMEOUT)
-					return m.defaultRenderer()
-				},
-			}
-		}
-	case "o": // open workspace path
-		return m, func() tea.Msg {
-			b := strings.Builder{}
-			for range m.maxrows {
-				b.WriteString("\n")
-			}
-			b.WriteString("💻 opening workspace")
-			return renderpaneswithcallbackcmd{
-				renderpanescmd: renderpanescmd{
-					main:   b.String(),
-					footer: m.generateFooter(),
-				},
-				callback: func() tea.Msg {
-					done := make(chan struct{}, 1)
-					go func() {
-						exec.Command("code", m.workspaces[m.cursor].Path()).Run()
-						done <- struct{}{}
-					}()
-					time.Sleep(MESSAGE_TIMEOUT)
-					<-done
-					return m.defaultRenderer()
-				},
-			}
-		}
-	case "/": // enable filter mode
-		m.startMode(modes.FILTER)
-		return m, m.filterRenderer
-	case ":": // enable command mode
-		m.startMode(modes.SELECT_COMMAND)
-		return m, m.commandSelectRenderer
-	}
-	return m, nil
+func (m *Application) defaultMode_handleKeyMsg(ctx context.Context, key tea.KeyMsg) (tea.Model, tea.Cmd) {
+	cmd, _ := m.resolveKey(ctx, key)
+	return m, cmd
 }
+
 func (m *Application) handleGlobalKeyMsg(key tea.KeyMsg) tea.Cmd {
 	switch key.Type {
 	case tea.KeyCtrlC:
@@ -444,41 +388,110 @@ func (m *Application) handleKeyMsg(ctx context.Context, key tea.KeyMsg) (tea.Mod
 	if cmd := m.handleGlobalKeyMsg(key); cmd != nil {
 		return m, cmd
 	}
+	var cmd tea.Cmd
 	switch m.mode {
 	case modes.SELECT_COMMAND:
-		return m.commandMode_handleKeyMsg(ctx, key)
+		_, cmd = m.commandMode_handleKeyMsg(ctx, key)
 	case modes.FILTER:
-		return m.filterMode_handleKeyMsg(key)
+		_, cmd = m.filterMode_handleKeyMsg(ctx, key)
+	case modes.PROMPT:
+		_, cmd = m.promptMode_handleKeyMsg(ctx, key)
+	case modes.HELP, modes.MESSAGE_LOG, modes.COPY, modes.DOCTOR:
+		cmd, _ = m.resolveKey(ctx, key)
 	default:
-		return m.defaultMode_handleKeyMsg(key)
+		_, cmd = m.defaultMode_handleKeyMsg(ctx, key)
 	}
+	// modes and panes change the room left for the list, cursors may have moved
+	m.reflow()
+	return m, cmd
 }
 
 func (m *Application) Cleanup() error {
-	return errors.Join(db.Close())
+	var err error
+	if m.watcher != nil {
+		err = m.watcher.Close()
+	}
+	return errors.Join(err, m.store.Close())
 }
 
 // interface
 func (m Application) Update(rawmsg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := rawmsg.(type) {
+	case tea.WindowSizeMsg:
+		m.width, m.height = msg.Width, msg.Height
+		m.reflow()
+		return m, m.activeRenderer()
 	case renderpanescmd:
 		m.mainPane = msg.main
 		m.footerPane = msg.footer
-	case renderpaneswithcallbackcmd:
-		m.mainPane = msg.main
-		m.footerPane = msg.footer
-		return m, msg.callback
+	case statusmessagecmd:
+		cmd := m.showStatus(msg.style, msg.text)
+		m.reflow()
+		return m, tea.Batch(cmd, m.activeRenderer())
+	case statusexpiredcmd:
+		cmd := m.expireStatus(int(msg))
+		m.reflow()
+		return m, tea.Batch(cmd, m.activeRenderer())
+	case renderdetailcmd:
+		if msg.load != m.detailLoad.id {
+			// the selection moved on while it loaded
+			break
+		}
+		m.detailLoad = detailLoad{id: m.detailLoad.id}
+		m.details[msg.path] = detail{text: msg.detail, at: time.Now()}
+		if w := m.selectedWorkspace(); m.isDetailActive && w != nil && w.Path() == msg.path {
+			m.detailPane = msg.detail
+		}
 	case addcheckpointcmd:
-		m.mainPane = string(msg)
+		return m, m.insertCheckpoint(m.ctx, msg.workspaces, msg.data)
 	case viewcheckpointscmd:
 		m.mainPane = string(msg)
-	case errormessage:
+	case workspaceschangedcmd:
 		if msg.err != nil {
+			m.watchError(msg.err)
+		} else {
+			m.setWorkspaces(msg.workspaces)
+			clear(m.details)
+			m.cancelDetailLoad()
+		}
+		m.reflow()
+		return m, tea.Batch(m.activeRenderer(), m.detailLoader(m.ctx), m.waitForWorkspaces)
+	case doctorcmd:
+		if msg.err != nil {
+			m.notify(SEVERITY_ERROR, fmt.Sprintf("doctor: %s", msg.err))
+			msg.problems = []db.Problem{}
+		}
+		for _, e := range msg.errs {
+			m.notify(SEVERITY_ERROR, fmt.Sprintf("doctor: %s", e))
+		}
+		m.problems = msg.problems
+		m.doctorCursor = min(m.doctorCursor, max(len(m.problems)-1, 0))
+		var cmd tea.Cmd
+		if msg.fixed > 0 {
+			cmd = m.showStatus(theme.SUCCESS, fmt.Sprintf("🩺 fixed %d problem(s)", msg.fixed))
+		}
+		m.reflow()
+		return m, tea.Batch(cmd, m.activeRenderer())
+	case commandoutputcmd:
+		m.outputPane = m.generateCommandOutputString(msg)
+		m.reflow()
+		return m, m.activeRenderer()
+	case errormessage:
+		if msg.err == nil {
+			break
+		}
+		if msg.fatal {
+			m.notify(SEVERITY_FATAL, msg.err.Error())
 			m.mainPane = msg.err.Error()
 			return m, tea.Quit
 		}
+		m.notify(SEVERITY_ERROR, msg.err.Error())
+		m.reflow()
+		return m, m.activeRenderer()
 	case tea.KeyMsg:
-		return m.handleKeyMsg(context.TODO(), msg)
+		return m.handleKeyMsg(m.ctx, msg)
+	case tea.MouseMsg:
+		return m.handleMouseMsg(m.ctx, msg)
 	case string:
 		panic(fmt.Sprintf("string type deprecated: '%s'", msg))
 	}
@@ -486,11 +499,37 @@ func (m Application) Update(rawmsg tea.Msg) (tea.Model, tea.Cmd) {
 }
 func (m Application) View() string {
 	b := strings.Builder{}
-	b.WriteString(m.mainPane)
-	b.WriteString("\n----------\n")
+	if m.isFullScreen() {
+		// the help takes over the whole screen
+		b.WriteString(m.mainPane)
+		b.WriteString("\n")
+		b.WriteString(m.footerPane)
+		return m.fitView(b.String())
+	}
+	if m.isDetailSide() {
+		b.WriteString(m.joinSide(m.mainPane, m.detailPane))
+	} else {
+		b.WriteString(m.mainPane)
+	}
+	b.WriteString("\n" + m.separator())
+	if m.outputPane != "" {
+		b.WriteString(m.outputPane)
+		b.WriteString("----------\n")
+	}
+	if m.isDetailBottom() {
+		b.WriteString(fitLines(m.detailPane, m.detailRows))
+		b.WriteString("----------\n")
+	}
+	if toasts := m.generateToastsString(); toasts != "" {
+		b.WriteString(toasts)
+		b.WriteString("----------\n")
+	}
+	b.WriteString(m.generateStatusString())
 	b.WriteString(m.footerPane)
-	return b.String()
+	return m.fitView(b.String())
 }
 func (m Application) Init() tea.Cmd {
-	return func() tea.Msg { return renderpanescmd{main: m.generateWorkspacesString(), footer: m.generateFooter()} }
+	return tea.Batch(
+		func() tea.Msg { return renderpanescmd{main: m.generateWorkspacesString(), footer: m.generateFooter()} },
+		m.waitForWorkspaces)
 }
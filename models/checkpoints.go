@@ -0,0 +1,87 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"time"
+	"workspaces-cli/models/db"
+	"workspaces-cli/pkg/debuglog"
+	"workspaces-cli/pkg/gitinfo"
+	"workspaces-cli/pkg/theme"
+	"workspaces-cli/pkg/workspaces"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func init() {
+	registerCommand(command{
+		name:        "add_checkpoint",
+		description: "write a checkpoint note for the selected workspace",
+		available:   hasSelectedWorkspace,
+		handler:     (*Application).addCheckpointHandler,
+	})
+	registerCommand(command{
+		name:        "view_checkpoints",
+		description: "show the checkpoints of the selected workspace",
+		available:   hasSelectedWorkspace,
+		handler:     (*Application).viewCheckpointsHandler,
+	})
+}
+
+func (m *Application) addCheckpointHandler(ctx context.Context) tea.Cmd {
+	ws := m.targets()
+	for i := range ws {
+		m.invalidateDetail(ws[i])
+	}
+	f, err := m.editor.CreateTemp()
+	if err != nil {
+		return func() tea.Msg { return errormessage{err: err} }
+	}
+	c := exec.Command(m.editor.Command(), m.editor.OpenFileArgs(f.Name())...)
+	start := time.Now()
+	return tea.ExecProcess(c, func(err error) tea.Msg {
+		debuglog.Process(ctx, c, start, err)
+		// here we need to load the data and render it
+		// then we'll need to ship it to the db
+		if err != nil {
+			return errormessage{err: fmt.Errorf("exec '%s': %w", m.editor.Command(), err)}
+		}
+		data, err := os.ReadFile(f.Name())
+		if err != nil {
+			return errormessage{err: fmt.Errorf("read file: %w", err)}
+		}
+		if len(data) == 0 {
+			return statusmessagecmd{style: theme.WARNING, text: "❎ no checkpoint data received"}
+		}
+		return addcheckpointcmd{workspaces: ws, data: data}
+	})
+}
+
+// insertCheckpoint: stores the note for each workspace. capturing the git state shells
+// out for every one of them, so this runs in a tea.Cmd and not in the exec callback.
+func (m *Application) insertCheckpoint(ctx context.Context, ws []workspaces.Workspace, data []byte) tea.Cmd {
+	return func() tea.Msg {
+		errs := []error{}
+		for i := range ws {
+			// the code the note is about, as it is once the note is written. the id file
+			// isn't part of it, repositories that predate its exclusion list it as untracked.
+			git := gitinfo.Capture(ws[i].Path(), workspaces.ID_FILE)
+			if err := m.store.InsertCheckpoint(ctx, ws[i], data, git); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", ws[i].DirEntry.Name(), err))
+				continue
+			}
+			m.recordActivity(ctx, ws[i], db.ACTIVITY_CHECKPOINT)
+		}
+		if err := errors.Join(errs...); err != nil {
+			return errormessage{err: fmt.Errorf("insert checkpoint: %w", err)}
+		}
+		return statusmessagecmd{style: theme.SUCCESS, text: fmt.Sprintf("✅ checkpoint inserted for %s", targetsLabel(ws))}
+	}
+}
+
+func (m *Application) viewCheckpointsHandler(ctx context.Context) tea.Cmd {
+	return func() tea.Msg { return viewcheckpointscmd("mock message from view checkpoints") }
+}
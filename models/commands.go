@@ -1,7 +1,9 @@
 package models
 
 import (
-	tea "github.com/charmbracelet/bubbletea"
+	"workspaces-cli/models/db"
+	"workspaces-cli/pkg/theme"
+	"workspaces-cli/pkg/workspaces"
 )
 
 // renderpanescmd: base command
@@ -10,16 +12,54 @@ type renderpanescmd struct {
 	footer string
 }
 
-// renderpaneswithcallbackcmd: provides a message for rendering and a post-rendering callback
-type renderpaneswithcallbackcmd struct {
-	renderpanescmd
-	callback tea.Cmd
+// statusmessagecmd: message for the status line, sent by commands that finish in the background
+type statusmessagecmd struct {
+	style theme.StyleName
+	text  string
 }
 
-type addcheckpointcmd string
+// statusexpiredcmd: the status message with this id was shown long enough
+type statusexpiredcmd int
+
+// renderdetailcmd: details loaded in the background for the workspace at path
+type renderdetailcmd struct {
+	load   int // the detailLoad it came from
+	path   string
+	detail string
+}
+
+// addcheckpointcmd: a note written in the editor, stored for the workspaces in the background
+type addcheckpointcmd struct {
+	workspaces []workspaces.Workspace
+	data       []byte
+}
 
 type viewcheckpointscmd string
 
+// errormessage: failure reported as a notification. only fatal errors end the program.
 type errormessage struct {
-	err error
+	err   error
+	fatal bool
+}
+
+// workspaceschangedcmd: the workspace root changed on disk, or watching it failed
+type workspaceschangedcmd struct {
+	workspaces []workspaces.Workspace
+	err        error
+}
+
+// doctorcmd: problems found by the doctor, after applying fixed fixes
+type doctorcmd struct {
+	problems []db.Problem
+	err      error
+	fixed    int
+	errs     []string
+}
+
+// commandoutputcmd: result of a user command run in the background
+type commandoutputcmd struct {
+	name      string
+	workspace string
+	output    string
+	err       error
 }
@@ -0,0 +1,192 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"workspaces-cli/models/db"
+	"workspaces-cli/models/modes"
+	"workspaces-cli/pkg/gitinfo"
+	"workspaces-cli/pkg/theme"
+	"workspaces-cli/pkg/workspaces"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// copyFormat: something about a workspace the copy menu puts on the clipboard. the name
+// is also the action of the copy mode that copies it.
+type copyFormat struct {
+	name        string
+	description string
+	value       func(ctx context.Context, store db.Store, w workspaces.Workspace) (string, error)
+}
+
+var (
+	copyFormats []copyFormat = []copyFormat{
+		{"path", "path", func(ctx context.Context, store db.Store, w workspaces.Workspace) (string, error) {
+			return w.Path(), nil
+		}},
+		{"name", "name", func(ctx context.Context, store db.Store, w workspaces.Workspace) (string, error) {
+			return w.DirEntry.Name(), nil
+		}},
+		{"cd", "cd command", func(ctx context.Context, store db.Store, w workspaces.Workspace) (string, error) {
+			return "cd " + shellQuote(w.Path()), nil
+		}},
+		{"remote", "git remote url", func(ctx context.Context, store db.Store, w workspaces.Workspace) (string, error) {
+			return gitinfo.RemoteURL(w.Path())
+		}},
+		{"branch", "git branch", func(ctx context.Context, store db.Store, w workspaces.Workspace) (string, error) {
+			return gitinfo.Branch(w.Path())
+		}},
+		{"checkpoint", "latest checkpoint", latestCheckpoint},
+		{"summary", "markdown summary", markdownSummary},
+	}
+)
+
+func init() {
+	registerCommand(command{
+		name:        "copy_menu",
+		description: "choose what to copy about the selected workspace",
+		available:   hasSelectedWorkspace,
+		handler: func(m *Application, ctx context.Context) tea.Cmd {
+			m.startMode(modes.COPY)
+			return m.defaultRenderer
+		},
+	})
+	registerAction(modes.COPY, action{"cancel", "close the copy menu", func(m *Application, ctx context.Context) tea.Cmd {
+		m.resetMode()
+		return m.defaultRenderer
+	}})
+	registerAction(modes.COPY, action{"execute", "copy the selected format", func(m *Application, ctx context.Context) tea.Cmd {
+		f := copyFormats[m.copyCursor]
+		m.resetMode()
+		return tea.Batch(m.defaultRenderer, m.copyToClipboard(ctx, f))
+	}})
+	registerAction(modes.COPY, action{"cursor_up", "move the cursor up", func(m *Application, ctx context.Context) tea.Cmd {
+		m.copyCursor = max(m.copyCursor-1, 0)
+		return m.defaultRenderer
+	}})
+	registerAction(modes.COPY, action{"cursor_down", "move the cursor down", func(m *Application, ctx context.Context) tea.Cmd {
+		m.copyCursor = min(m.copyCursor+1, len(copyFormats)-1)
+		return m.defaultRenderer
+	}})
+	for _, f := range copyFormats {
+		registerAction(modes.COPY, action{f.name, "copy the " + f.description, func(m *Application, ctx context.Context) tea.Cmd {
+			m.resetMode()
+			return tea.Batch(m.defaultRenderer, m.copyToClipboard(ctx, f))
+		}})
+	}
+}
+
+// shellQuote: quotes s for sh unless every character is safe as is
+func shellQuote(s string) string {
+	if s != "" && !strings.ContainsFunc(s, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("/._-+~", r))
+	}) {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func latestCheckpoint(ctx context.Context, store db.Store, w workspaces.Workspace) (string, error) {
+	checkpoints, err := store.GetCheckpoints(ctx, w, 1)
+	if err != nil {
+		return "", err
+	}
+	if len(checkpoints) == 0 {
+		return "", errors.New("no checkpoints")
+	}
+	return checkpoints[0].Value, nil
+}
+
+// markdownSummary: what someone else needs to know about the workspace, ready to paste
+// into a chat or a ticket
+func markdownSummary(ctx context.Context, store db.Store, w workspaces.Workspace) (string, error) {
+	b := strings.Builder{}
+	b.WriteString(fmt.Sprintf("### %s\n\n", w.DirEntry.Name()))
+	b.WriteString(fmt.Sprintf("- path: `%s`\n", w.Path()))
+	if gitinfo.IsRepository(w.Path()) {
+		if remote, err := gitinfo.RemoteURL(w.Path()); err == nil {
+			b.WriteString(fmt.Sprintf("- remote: %s\n", remote))
+		}
+		if branch, err := gitinfo.Branch(w.Path()); err == nil {
+			b.WriteString(fmt.Sprintf("- branch: `%s`\n", branch))
+		}
+	}
+	if langs, _, err := w.Languages(ctx); err == nil && len(langs) > 0 {
+		b.WriteString(fmt.Sprintf("- languages: %s\n", strings.Join(langs, ", ")))
+	}
+	b.WriteString(fmt.Sprintf("- modified: %s\n", w.ModTime().Format("2006-01-02")))
+	if tags, err := store.GetTags(ctx, w); err == nil && len(tags) > 0 {
+		b.WriteString(fmt.Sprintf("- tags: %s\n", strings.Join(tags, ", ")))
+	}
+	checkpoints, err := store.GetCheckpoints(ctx, w, 1)
+	if err != nil {
+		return "", err
+	}
+	if len(checkpoints) > 0 {
+		when := checkpoints[0].Date.Format("2006-01-02")
+		if git := checkpoints[0].Git; !git.IsZero() {
+			when += " on `" + git.String() + "`"
+		}
+		b.WriteString(fmt.Sprintf("\nlatest checkpoint, %s:\n\n", when))
+		for _, l := range strings.Split(checkpoints[0].Value, "\n") {
+			b.WriteString("> " + l + "\n")
+		}
+	}
+	return b.String(), nil
+}
+
+// copyToClipboard: resolves the format for the marked or selected workspaces in the
+// background, git and the db may take a moment. values of several workspaces go one per line.
+func (m *Application) copyToClipboard(ctx context.Context, f copyFormat) tea.Cmd {
+	ws := m.targets()
+	if len(ws) == 0 {
+		return nil
+	}
+	return func() tea.Msg {
+		values := make([]string, len(ws))
+		for i := range ws {
+			v, err := f.value(ctx, m.store, ws[i])
+			if err != nil {
+				return errormessage{err: fmt.Errorf("copy %s of %s: %w", f.description, ws[i].DirEntry.Name(), err)}
+			}
+			values[i] = v
+		}
+		if err := m.clipboard.Write(strings.Join(values, "\n")); err != nil {
+			return errormessage{err: fmt.Errorf("copy %s: %w", f.description, err)}
+		}
+		slog.DebugContext(ctx, "copied to clipboard", "backend", m.clipboard.Name(), "format", f.name, "workspaces", len(ws))
+		return statusmessagecmd{style: theme.SUCCESS, text: fmt.Sprintf("📋 copied %s of %s to clipboard", f.description, targetsLabel(ws))}
+	}
+}
+
+func copyFormatByName(name string) copyFormat {
+	return copyFormats[slices.IndexFunc(copyFormats, func(f copyFormat) bool { return f.name == name })]
+}
+
+func (m *Application) generateCopyMenuString() string {
+	namepadding := 0
+	for i := range copyFormats {
+		namepadding = max(namepadding, len(copyFormats[i].description))
+	}
+	b := strings.Builder{}
+	b.WriteString(theme.Render(theme.PROMPT, "↳ COPY") + "\n")
+	for i := range copyFormats {
+		f := &copyFormats[i]
+		key := ""
+		if keys := m.keymap.KeysFor(modes.Name(modes.COPY), f.name); len(keys) > 0 {
+			key = theme.Render(theme.KEY, fmt.Sprintf("[%s]", strings.Join(keys, "/")))
+		}
+		line := fmt.Sprintf("%-*s %s", namepadding, f.description, key)
+		if m.copyCursor == i {
+			b.WriteString(" > " + line + "\n")
+		} else {
+			b.WriteString("   " + line + "\n")
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,224 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	BACKUP_PREFIX   string        = "workspaces-"
+	BACKUP_SUFFIX   string        = ".sql"
+	BACKUP_INTERVAL time.Duration = time.Hour // startup backups are skipped when the newest one is more recent
+	BACKUP_DATE     string        = "20060102T150405.000Z"
+
+	BACKUP_STARTUP   string = "startup"
+	BACKUP_MIGRATION string = "migration" // taken before the schema changes
+	BACKUP_MANUAL    string = "manual"
+	BACKUP_RESTORE   string = "restore" // the database a restore replaced
+	BACKUP_REPAIR    string = "repair"  // taken before the doctor changes anything
+)
+
+// Backups: where snapshots of the database go and how many of them are kept
+type Backups struct {
+	Dir  string
+	Keep int // 0 turns the automatic backups off and keeps every manual one
+}
+
+// Backup: a snapshot of the database, named after when and why it was taken
+type Backup struct {
+	File   string
+	Date   time.Time
+	Reason string
+	Size   int64
+}
+
+func backupName(date time.Time, reason string) string {
+	return BACKUP_PREFIX + date.UTC().Format(BACKUP_DATE) + "-" + reason + BACKUP_SUFFIX
+}
+
+func parseBackupName(name string) (time.Time, string, bool) {
+	rest, ok := strings.CutPrefix(name, BACKUP_PREFIX)
+	if !ok {
+		return time.Time{}, "", false
+	}
+	rest, ok = strings.CutSuffix(rest, BACKUP_SUFFIX)
+	if !ok {
+		return time.Time{}, "", false
+	}
+	ts, reason, _ := strings.Cut(rest, "-")
+	date, err := time.Parse(BACKUP_DATE, ts)
+	if err != nil {
+		return time.Time{}, "", false
+	}
+	return date, reason, true
+}
+
+// ListBackups: the snapshots in dir, newest first
+func ListBackups(dir string) ([]Backup, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	backups := []Backup{}
+	for _, e := range entries {
+		date, reason, ok := parseBackupName(e.Name())
+		if !ok || e.IsDir() {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			return nil, err
+		}
+		backups = append(backups, Backup{File: path.Join(dir, e.Name()), Date: date, Reason: reason, Size: info.Size()})
+	}
+	slices.SortFunc(backups, func(a, b Backup) int { return b.Date.Compare(a.Date) })
+	return backups, nil
+}
+
+// pruneBackups: deletes all but the Keep newest snapshots
+func pruneBackups(ctx context.Context, b Backups) error {
+	if b.Keep <= 0 {
+		return nil
+	}
+	backups, err := ListBackups(b.Dir)
+	if err != nil {
+		return err
+	}
+	errs := []error{}
+	for _, old := range backups[min(b.Keep, len(backups)):] {
+		slog.DebugContext(ctx, "prune backup", "file", old.File)
+		errs = append(errs, os.Remove(old.File))
+	}
+	return errors.Join(errs...)
+}
+
+// vacuumInto: consistent copy of the database, readers and writers can carry on meanwhile
+func vacuumInto(ctx context.Context, db querier, file string) error {
+	q := "vacuum into ?"
+	start := time.Now()
+	_, err := db.ExecContext(ctx, q, file)
+	logQuery(ctx, q, start, err)
+	return err
+}
+
+func backup(ctx context.Context, db querier, b Backups, reason string) (Backup, error) {
+	if err := os.MkdirAll(b.Dir, 0o755); err != nil {
+		return Backup{}, err
+	}
+	now := time.Now()
+	file := path.Join(b.Dir, backupName(now, reason))
+	if err := vacuumInto(ctx, db, file); err != nil {
+		return Backup{}, fmt.Errorf("backup: %w", err)
+	}
+	info, err := os.Stat(file)
+	if err != nil {
+		return Backup{}, err
+	}
+	slog.DebugContext(ctx, "backup", "file", file, "reason", reason, "size", info.Size())
+	date, _, _ := parseBackupName(path.Base(file))
+	return Backup{File: file, Date: date, Reason: reason, Size: info.Size()}, pruneBackups(ctx, b)
+}
+
+// Backup: snapshot of the database into the backup dir, the oldest ones beyond Keep go
+func (s *SQLite) Backup(ctx context.Context, b Backups, reason string) (Backup, error) {
+	return backup(ctx, s.db, b, reason)
+}
+
+// StartupBackup: takes the automatic backup unless they are off or a recent one exists
+func (s *SQLite) StartupBackup(ctx context.Context, b Backups) error {
+	if b.Dir == "" || b.Keep == 0 {
+		return nil
+	}
+	backups, err := ListBackups(b.Dir)
+	if err != nil {
+		return err
+	}
+	if len(backups) > 0 && time.Since(backups[0].Date) < BACKUP_INTERVAL {
+		return nil
+	}
+	_, err = s.Backup(ctx, b, BACKUP_STARTUP)
+	return err
+}
+
+// BackupBeforeRepair: snapshot of the database before the doctor changes it, taken even
+// with the automatic backups off. nothing is taken without a backup dir.
+func (s *SQLite) BackupBeforeRepair(ctx context.Context) (Backup, error) {
+	if s.backups.Dir == "" {
+		return Backup{}, nil
+	}
+	return s.Backup(ctx, s.backups, BACKUP_REPAIR)
+}
+
+// hasData: whether the database holds anything worth a backup
+func hasData(ctx context.Context, db querier) (bool, error) {
+	n, err := queryInt(ctx, db, "select (select count(*) from workspaces) + (select count(*) from checkpoints)")
+	return n > 0, err
+}
+
+func backupBeforeMigrate(ctx context.Context, db querier, b Backups) error {
+	if b.Dir == "" || b.Keep == 0 {
+		return nil
+	}
+	version, err := queryInt(ctx, db, "pragma user_version")
+	if err != nil || version >= len(migrations) {
+		return err
+	}
+	if ok, err := hasData(ctx, db); err != nil || !ok {
+		return err
+	}
+	_, err = backup(ctx, db, b, BACKUP_MIGRATION)
+	return err
+}
+
+// Restore: replaces the database file with the snapshot. the snapshot is checked first
+// and the database it replaces is backed up, so a restore can itself be undone. nothing
+// may have the database open meanwhile.
+func Restore(ctx context.Context, file string, from string, b Backups) (Backup, error) {
+	src, err := sql.Open("sqlite3", "file:"+from+"?mode=ro")
+	if err != nil {
+		return Backup{}, err
+	}
+	defer src.Close()
+	issues, err := integrityCheck(ctx, src)
+	if err != nil {
+		return Backup{}, fmt.Errorf("check '%s': %w", from, err)
+	}
+	if len(issues) > 0 {
+		return Backup{}, fmt.Errorf("'%s' is damaged: %s", from, strings.Join(issues, "; "))
+	}
+	// copied before the current database is backed up, rotation may remove the snapshot
+	tmp := file + ".restore"
+	os.Remove(tmp)
+	if err := vacuumInto(ctx, src, tmp); err != nil {
+		return Backup{}, fmt.Errorf("copy '%s': %w", from, err)
+	}
+	var saved Backup
+	if _, err := os.Stat(file); err == nil {
+		current, err := sql.Open("sqlite3", dsn(file))
+		if err != nil {
+			return Backup{}, errors.Join(err, os.Remove(tmp))
+		}
+		saved, err = backup(ctx, current, b, BACKUP_RESTORE)
+		if err := errors.Join(err, current.Close()); err != nil {
+			return Backup{}, errors.Join(fmt.Errorf("back up current database: %w", err), os.Remove(tmp))
+		}
+	}
+	// a journal left behind would be replayed on top of the restored file
+	for _, suffix := range []string{"-wal", "-shm"} {
+		if err := os.Remove(file + suffix); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return Backup{}, err
+		}
+	}
+	return saved, os.Rename(tmp, file)
+}
@@ -6,8 +6,10 @@ import (
 	_ "embed"
 	"errors"
 	"fmt"
+	"log/slog"
 	"strings"
 	"time"
+	"workspaces-cli/pkg/gitinfo"
 	"workspaces-cli/pkg/workspaces"
 
 	"github.com/google/uuid"
@@ -15,13 +17,34 @@ import (
 )
 
 var (
-	database *sql.DB = nil
 	//go:embed resources/createTable_checkpoints.sql
 	createTable_checkpoints string
 	//go:embed resources/createTable_workspaces.sql
 	createTable_workspaces string
+	//go:embed resources/createTable_tags.sql
+	createTable_tags string
+	//go:embed resources/migration_1.sql
+	migration_1 string
+	//go:embed resources/migration_2.sql
+	migration_2 string
+	//go:embed resources/migration_3.sql
+	migration_3 string
+
+	// migrations: migrations[i] takes the schema from user_version i to i+1
+	migrations []string = []string{migration_1, migration_2, migration_3}
 )
 
+// SQLite: the default store, one database file for every workspace
+type SQLite struct {
+	db      *sql.DB
+	backups Backups // where the snapshots before repairs go
+}
+
+// logQuery: records q with its duration once it has run
+func logQuery(ctx context.Context, q string, start time.Time, err error) {
+	slog.DebugContext(ctx, "db query", "query", q, "duration", time.Since(start), "error", err)
+}
+
 func createWorkspacesTable(ctx context.Context, db *sql.DB) error {
 	_, err := db.ExecContext(ctx, createTable_workspaces)
 	return err
@@ -31,72 +54,403 @@ func createCheckpointsTable(ctx context.Context, db *sql.DB) error {
 	return err
 }
 
-func Open(ctx context.Context, file string) error {
-	if database == nil {
-		db, err := sql.Open("sqlite3", file)
+func createTagsTable(ctx context.Context, db *sql.DB) error {
+	_, err := db.ExecContext(ctx, createTable_tags)
+	return err
+}
+
+// migrate: runs the migrations the database hasn't seen yet, each in its own transaction
+// along with the version bump. the version is read under the write lock, so a second
+// instance starting at the same time waits and then finds nothing left to do.
+func migrate(ctx context.Context, db *sql.DB) error {
+	for done := false; !done; {
+		err := withTx(ctx, db, func(tx *sql.Tx) error {
+			var version int
+			if err := tx.QueryRowContext(ctx, "pragma user_version").Scan(&version); err != nil {
+				return fmt.Errorf("read schema version: %w", err)
+			}
+			if version >= len(migrations) {
+				done = true
+				return nil
+			}
+			slog.DebugContext(ctx, "migrate database", "from", version, "to", version+1)
+			if _, err := tx.ExecContext(ctx, migrations[version]); err != nil {
+				return fmt.Errorf("migration %d: %w", version+1, err)
+			}
+			// pragmas don't take parameters
+			if _, err := tx.ExecContext(ctx, fmt.Sprintf("pragma user_version = %d", version+1)); err != nil {
+				return fmt.Errorf("migration %d: %w", version+1, err)
+			}
+			return nil
+		})
 		if err != nil {
 			return err
 		}
-		database = db
-		return errors.Join(createWorkspacesTable(ctx, db), createCheckpointsTable(ctx, db))
 	}
 	return nil
 }
 
-func Close() error {
-	if database == nil {
+// OpenSQLite: opens the database and brings its schema up to date, backing it up first
+// when there is a migration to run
+func OpenSQLite(ctx context.Context, file string, b Backups) (*SQLite, error) {
+	slog.DebugContext(ctx, "open database", "file", file)
+	db, err := sql.Open("sqlite3", dsn(file))
+	if err != nil {
+		return nil, err
+	}
+	if err := errors.Join(createWorkspacesTable(ctx, db), createCheckpointsTable(ctx, db), createTagsTable(ctx, db)); err != nil {
+		return nil, errors.Join(err, db.Close())
+	}
+	if err := backupBeforeMigrate(ctx, db, b); err != nil {
+		return nil, errors.Join(fmt.Errorf("backup before migration: %w", err), db.Close())
+	}
+	if err := migrate(ctx, db); err != nil {
+		return nil, errors.Join(err, db.Close())
+	}
+	return &SQLite{db: db, backups: b}, nil
+}
+
+func (s *SQLite) Close() error {
+	return s.db.Close()
+}
+
+// queryWorkspace: id, name and path of the single workspace row q selects
+func queryWorkspace(ctx context.Context, db querier, q string, args ...any) (string, string, string, error) {
+	var wid, name, wpath string
+	start := time.Now()
+	err := db.QueryRowContext(ctx, q, args...).Scan(&wid, &name, &wpath)
+	logQuery(ctx, q, start, err)
+	return wid, name, wpath, err
+}
+
+// relinkWorkspace: points the row of id at the workspace when it was moved or renamed
+func relinkWorkspace(ctx context.Context, db querier, wid string, w *workspaces.Workspace) error {
+	_, name, wpath, err := queryWorkspace(ctx, db, "select id, name, path from workspaces where id = ?", wid)
+	if err != nil {
+		return err
+	}
+	if name == w.DirEntry.Name() && wpath == w.Path() {
 		return nil
 	}
-	return database.Close()
+	slog.DebugContext(ctx, "relink workspace", "id", wid, "from", wpath, "to", w.Path())
+	q := "update workspaces set name = ?, path = ? where id = ?"
+	start := time.Now()
+	_, err = db.ExecContext(ctx, q, w.DirEntry.Name(), w.Path(), wid)
+	logQuery(ctx, q, start, err)
+	if err != nil {
+		return fmt.Errorf("relink workspace: %w", err)
+	}
+	return nil
 }
 
-func getWorkspaceId(ctx context.Context, w *workspaces.Workspace) (string, error) {
-	q := fmt.Sprintf("select id from workspaces where name == '%s'", w.DirEntry.Name())
-	row := database.QueryRowContext(ctx, q)
-	var wid string
-	err := row.Scan(&wid)
+func (s *SQLite) MoveWorkspace(ctx context.Context, from string, to workspaces.Workspace) error {
+	return withTx(ctx, s.db, func(tx *sql.Tx) error {
+		wid, err := to.ID()
+		if err != nil {
+			return fmt.Errorf("read id file: %w", err)
+		}
+		if wid == "" {
+			wid, _, _, err = queryWorkspace(ctx, tx, "select id, name, path from workspaces where path = ?", from)
+		}
+		if err == nil {
+			err = relinkWorkspace(ctx, tx, wid, &to)
+		}
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		return err
+	})
+}
+
+// getWorkspaceId: id of the recorded workspace, sql.ErrNoRows when it was never recorded.
+// the id file decides, workspaces without one are looked up by path. it only reads, rows
+// of moved workspaces are re-linked by ensureWorkspaceId.
+func getWorkspaceId(ctx context.Context, db querier, w *workspaces.Workspace) (string, error) {
+	wid, err := w.ID()
+	if err != nil {
+		return "", fmt.Errorf("read id file: %w", err)
+	}
+	if wid != "" {
+		wid, _, _, err = queryWorkspace(ctx, db, "select id, name, path from workspaces where id = ?", wid)
+		return wid, err
+	}
+	wid, _, _, err = queryWorkspace(ctx, db, "select id, name, path from workspaces where path = ?", w.Path())
 	return wid, err
 }
 
-func insertWorkspace(ctx context.Context, w *workspaces.Workspace) (string, error) {
+func insertWorkspace(ctx context.Context, db querier, wid string, w *workspaces.Workspace) error {
 	if w == nil {
-		return "", fmt.Errorf("workspace is nil")
+		return fmt.Errorf("workspace is nil")
 	}
-	wid := uuid.New().String()
 	q := "insert into workspaces (id, name, path) values(?, ?, ?)"
-	_, err := database.ExecContext(ctx, q, wid, w.DirEntry.Name(), w.Path())
+	start := time.Now()
+	_, err := db.ExecContext(ctx, q, wid, w.DirEntry.Name(), w.Path())
+	logQuery(ctx, q, start, err)
 	if err != nil {
-		return "", fmt.Errorf("exec query: %w", err)
+		return fmt.Errorf("exec query: %w", err)
 	}
-	return wid, nil
+	return nil
 }
 
-func insertCheckpoint(ctx context.Context, wid string, data []byte) (string, error) {
+func insertCheckpoint(ctx context.Context, db querier, wid string, c Checkpoint) error {
 	if wid == "" {
-		return "", fmt.Errorf("empty workspace id")
+		return fmt.Errorf("empty workspace id")
 	}
-	cid := uuid.New().String()
-	q := "insert into checkpoints (id, workspaceid, value, date) values(?, ?, ?, ?)"
-	_, err := database.ExecContext(ctx, q, cid, wid, strings.TrimSpace(string(data)), time.Now().In(time.UTC).Unix())
+	q := "insert into checkpoints (id, workspaceid, value, date, branch, head, dirty, diffstat) values(?, ?, ?, ?, ?, ?, ?, ?)"
+	start := time.Now()
+	_, err := db.ExecContext(ctx, q, c.Id, wid, strings.TrimSpace(c.Value), c.Date.In(time.UTC).Unix(),
+		c.Git.Branch, c.Git.Head, c.Git.Dirty, c.Git.DiffStat)
+	logQuery(ctx, q, start, err)
 	if err != nil {
-		return "", fmt.Errorf("exec query: %w", err)
+		return fmt.Errorf("exec query: %w", err)
 	}
-	return cid, nil
+	return nil
 }
-func InsertCheckpoint(ctx context.Context, w workspaces.Workspace, data []byte) error {
-	wid, err := getWorkspaceId(ctx, &w)
+
+// ensureWorkspaceId: id of the workspace, recording it first if it is new and re-linking
+// it when it moved. it writes, so it only ever runs in a transaction, see withWorkspace.
+func ensureWorkspaceId(ctx context.Context, db querier, w *workspaces.Workspace) (string, error) {
+	fileid, err := w.ID()
+	if err != nil {
+		return "", fmt.Errorf("read id file: %w", err)
+	}
+	wid, err := getWorkspaceId(ctx, db, w)
 	if errors.Is(err, sql.ErrNoRows) {
-		wwid, wierr := insertWorkspace(ctx, &w)
-		if wierr != nil {
-			return fmt.Errorf("insert workspace: %w", err)
+		wid = fileid
+		if wid == "" {
+			wid = uuid.New().String()
+		}
+		if err := insertWorkspace(ctx, db, wid, w); err != nil {
+			return "", fmt.Errorf("insert workspace: %w", err)
 		}
-		wid = wwid
 	} else if err != nil {
-		return fmt.Errorf("get workspace id: %w", err)
+		return "", fmt.Errorf("get workspace id: %w", err)
+	} else if fileid != "" {
+		if err := relinkWorkspace(ctx, db, wid, w); err != nil {
+			return "", err
+		}
 	}
 	if wid == "" {
-		return fmt.Errorf("workspace id is empty: '%s'", w.DirEntry.Name())
+		return "", fmt.Errorf("workspace id is empty: '%s'", w.DirEntry.Name())
 	}
-	_, err = insertCheckpoint(ctx, wid, data)
-	return err
+	return wid, nil
+}
+
+// withWorkspace: runs fn in a transaction with the id of the workspace, recorded if
+// needed. once committed the id is written to the workspace so that its rows follow it
+// when it is moved; where that isn't possible the workspace is known by its path alone.
+// a rolled back or retried transaction leaves no id file naming a row that doesn't exist.
+func (s *SQLite) withWorkspace(ctx context.Context, w workspaces.Workspace, fn func(tx *sql.Tx, wid string) error) error {
+	var wid string
+	err := withTx(ctx, s.db, func(tx *sql.Tx) error {
+		var err error
+		if wid, err = ensureWorkspaceId(ctx, tx, &w); err != nil {
+			return err
+		}
+		return fn(tx, wid)
+	})
+	if err != nil {
+		return err
+	}
+	fileid, err := w.ID()
+	if err != nil {
+		slog.DebugContext(ctx, "read id file", "workspace", w.Path(), "error", err)
+	} else if fileid == "" {
+		if err := w.SetID(wid); err != nil {
+			slog.DebugContext(ctx, "write id file", "workspace", w.Path(), "error", err)
+		}
+	} else if err := w.ExcludeID(); err != nil {
+		// id files written before they were excluded
+		slog.DebugContext(ctx, "exclude id file", "workspace", w.Path(), "error", err)
+	}
+	return nil
+}
+
+// InsertCheckpoint: records the workspace if needed and the checkpoint, in one transaction
+func (s *SQLite) InsertCheckpoint(ctx context.Context, w workspaces.Workspace, data []byte, git gitinfo.State) error {
+	return s.withWorkspace(ctx, w, func(tx *sql.Tx, wid string) error {
+		return insertCheckpoint(ctx, tx, wid, Checkpoint{Id: uuid.New().String(), Value: string(data), Date: time.Now(), Git: git})
+	})
+}
+
+func (s *SQLite) ImportCheckpoint(ctx context.Context, w workspaces.Workspace, c Checkpoint) error {
+	return s.withWorkspace(ctx, w, func(tx *sql.Tx, wid string) error {
+		taken, err := queryInt(ctx, tx, "select count(*) from checkpoints where id = ?", c.Id)
+		if err != nil {
+			return err
+		}
+		if c.Id == "" || taken > 0 {
+			c.Id = uuid.New().String()
+		}
+		return insertCheckpoint(ctx, tx, wid, c)
+	})
+}
+
+func (s *SQLite) AddTag(ctx context.Context, w workspaces.Workspace, name string) error {
+	return s.withWorkspace(ctx, w, func(tx *sql.Tx, wid string) error {
+		q := "insert or ignore into tags (workspaceid, name) values(?, ?)"
+		start := time.Now()
+		_, err := tx.ExecContext(ctx, q, wid, name)
+		logQuery(ctx, q, start, err)
+		if err != nil {
+			return fmt.Errorf("exec query: %w", err)
+		}
+		return nil
+	})
+}
+
+// GetCheckpoints: latest checkpoints of the workspace, newest first. a workspace that
+// was never recorded has no checkpoints.
+func (s *SQLite) GetCheckpoints(ctx context.Context, w workspaces.Workspace, limit int) ([]Checkpoint, error) {
+	wid, err := getWorkspaceId(ctx, s.db, &w)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("get workspace id: %w", err)
+	}
+	return s.queryCheckpoints(ctx, "select id, workspaceid, value, date, branch, head, dirty, diffstat from checkpoints where workspaceid = ? order by date desc, rowid desc limit ?", wid, limit)
+}
+
+// Checkpoints: every checkpoint of the recorded workspace, oldest first
+func (s *SQLite) Checkpoints(ctx context.Context, wid string) ([]Checkpoint, error) {
+	return s.queryCheckpoints(ctx, "select id, workspaceid, value, date, branch, head, dirty, diffstat from checkpoints where workspaceid = ? order by date, rowid", wid)
+}
+
+func (s *SQLite) queryCheckpoints(ctx context.Context, q string, args ...any) ([]Checkpoint, error) {
+	start := time.Now()
+	rows, err := s.db.QueryContext(ctx, q, args...)
+	logQuery(ctx, q, start, err)
+	if err != nil {
+		return nil, fmt.Errorf("exec query: %w", err)
+	}
+	defer rows.Close()
+	checkpoints := []Checkpoint{}
+	for rows.Next() {
+		var (
+			c    Checkpoint
+			date int64
+		)
+		if err := rows.Scan(&c.Id, &c.WorkspaceId, &c.Value, &date, &c.Git.Branch, &c.Git.Head, &c.Git.Dirty, &c.Git.DiffStat); err != nil {
+			return nil, fmt.Errorf("scan row: %w", err)
+		}
+		c.Date = time.Unix(date, 0)
+		checkpoints = append(checkpoints, c)
+	}
+	return checkpoints, rows.Err()
+}
+
+func (s *SQLite) GetTags(ctx context.Context, w workspaces.Workspace) ([]string, error) {
+	wid, err := getWorkspaceId(ctx, s.db, &w)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("get workspace id: %w", err)
+	}
+	q := "select name from tags where workspaceid = ? order by name"
+	start := time.Now()
+	rows, err := s.db.QueryContext(ctx, q, wid)
+	logQuery(ctx, q, start, err)
+	if err != nil {
+		return nil, fmt.Errorf("exec query: %w", err)
+	}
+	defer rows.Close()
+	tags := []string{}
+	for rows.Next() {
+		var t string
+		if err := rows.Scan(&t); err != nil {
+			return nil, fmt.Errorf("scan row: %w", err)
+		}
+		tags = append(tags, t)
+	}
+	return tags, rows.Err()
+}
+
+// allTags: tags by workspace id
+func (s *SQLite) allTags(ctx context.Context) (map[string][]string, error) {
+	q := "select workspaceid, name from tags order by name"
+	start := time.Now()
+	rows, err := s.db.QueryContext(ctx, q)
+	logQuery(ctx, q, start, err)
+	if err != nil {
+		return nil, fmt.Errorf("exec query: %w", err)
+	}
+	defer rows.Close()
+	tags := map[string][]string{}
+	for rows.Next() {
+		var wid, name string
+		if err := rows.Scan(&wid, &name); err != nil {
+			return nil, fmt.Errorf("scan row: %w", err)
+		}
+		tags[wid] = append(tags[wid], name)
+	}
+	return tags, rows.Err()
+}
+
+func (s *SQLite) Workspaces(ctx context.Context) ([]Record, error) {
+	tags, err := s.allTags(ctx)
+	if err != nil {
+		return nil, err
+	}
+	q := "select id, name, path from workspaces order by name"
+	start := time.Now()
+	rows, err := s.db.QueryContext(ctx, q)
+	logQuery(ctx, q, start, err)
+	if err != nil {
+		return nil, fmt.Errorf("exec query: %w", err)
+	}
+	defer rows.Close()
+	records := []Record{}
+	for rows.Next() {
+		var r Record
+		if err := rows.Scan(&r.Id, &r.Name, &r.Path); err != nil {
+			return nil, fmt.Errorf("scan row: %w", err)
+		}
+		r.Tags = tags[r.Id]
+		records = append(records, r)
+	}
+	return records, rows.Err()
+}
+
+func (s *SQLite) RecordActivity(ctx context.Context, w workspaces.Workspace, kind string) error {
+	return s.withWorkspace(ctx, w, func(tx *sql.Tx, wid string) error {
+		q := "insert into activity (workspaceid, kind, date) values(?, ?, ?)"
+		start := time.Now()
+		_, err := tx.ExecContext(ctx, q, wid, kind, time.Now().In(time.UTC).Unix())
+		logQuery(ctx, q, start, err)
+		if err != nil {
+			return fmt.Errorf("exec query: %w", err)
+		}
+		return nil
+	})
+}
+
+// GetActivity: latest activity of the workspace, newest first
+func (s *SQLite) GetActivity(ctx context.Context, w workspaces.Workspace, limit int) ([]Activity, error) {
+	wid, err := getWorkspaceId(ctx, s.db, &w)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("get workspace id: %w", err)
+	}
+	q := "select workspaceid, kind, date from activity where workspaceid = ? order by date desc, rowid desc limit ?"
+	start := time.Now()
+	rows, err := s.db.QueryContext(ctx, q, wid, limit)
+	logQuery(ctx, q, start, err)
+	if err != nil {
+		return nil, fmt.Errorf("exec query: %w", err)
+	}
+	defer rows.Close()
+	activity := make([]Activity, 0, limit)
+	for rows.Next() {
+		var (
+			a    Activity
+			date int64
+		)
+		if err := rows.Scan(&a.WorkspaceId, &a.Kind, &date); err != nil {
+			return nil, fmt.Errorf("scan row: %w", err)
+		}
+		a.Date = time.Unix(date, 0)
+		activity = append(activity, a)
+	}
+	return activity, rows.Err()
 }
@@ -0,0 +1,302 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+	"time"
+	"workspaces-cli/pkg/workspaces"
+)
+
+type ProblemKind string
+
+const (
+	PROBLEM_MISSING_PATH       ProblemKind = "missing path"       // the recorded directory is gone
+	PROBLEM_ORPHAN_CHECKPOINTS ProblemKind = "orphan checkpoints" // checkpoints of no recorded workspace
+	PROBLEM_ORPHAN_TAGS        ProblemKind = "orphan tags"
+	PROBLEM_ORPHAN_ACTIVITY    ProblemKind = "orphan activity"
+	PROBLEM_DUPLICATE_NAME     ProblemKind = "duplicate name" // several rows for one name, usually a move before ids
+	PROBLEM_INTEGRITY          ProblemKind = "integrity"
+	PROBLEM_SCHEMA             ProblemKind = "schema"
+)
+
+type Fix string
+
+const (
+	FIX_NONE   Fix = ""
+	FIX_RELINK Fix = "relink" // point the row at Target
+	FIX_MERGE  Fix = "merge"  // move the rows of WorkspaceId over to Into and drop it
+	FIX_PURGE  Fix = "purge"  // delete the rows
+)
+
+// Problem: an inconsistency found by Diagnose, with the fix Repair would apply
+type Problem struct {
+	Kind        ProblemKind
+	WorkspaceId string
+	Name        string
+	Path        string
+	Detail      string
+	Fix         Fix
+	Target      *workspaces.Workspace // FIX_RELINK
+	Into        string                // FIX_MERGE
+	Purgeable   bool                  // FIX_PURGE may be asked for, it is never the fix by default
+	ByName      bool                  // FIX_RELINK to a workspace of the same name, nothing else ties them
+}
+
+// Purge: the problem with its rows to be purged instead of its own fix
+func (p Problem) Purge() Problem {
+	p.Fix = FIX_PURGE
+	return p
+}
+
+func (p Problem) String() string {
+	s := string(p.Kind)
+	if p.Name != "" {
+		s += fmt.Sprintf(": %s (%s)", p.Name, p.Path)
+	}
+	if p.Detail != "" {
+		s += ": " + p.Detail
+	}
+	return s
+}
+
+// FixDescription: what Repair does about the problem
+func (p Problem) FixDescription() string {
+	switch p.Fix {
+	case FIX_RELINK:
+		if p.ByName {
+			return fmt.Sprintf("relink to %s, matched by name only", p.Target.Path())
+		}
+		return fmt.Sprintf("relink to %s", p.Target.Path())
+	case FIX_MERGE:
+		return fmt.Sprintf("merge into %s", p.Into)
+	case FIX_PURGE:
+		return "purge"
+	}
+	if p.Purgeable {
+		return "no automatic fix, its history can be purged"
+	}
+	return "no automatic fix"
+}
+
+type workspaceRow struct {
+	id, name, path string
+}
+
+func workspaceRows(ctx context.Context, db querier) ([]workspaceRow, error) {
+	q := "select id, name, path from workspaces order by name"
+	start := time.Now()
+	rows, err := db.QueryContext(ctx, q)
+	logQuery(ctx, q, start, err)
+	if err != nil {
+		return nil, fmt.Errorf("exec query: %w", err)
+	}
+	defer rows.Close()
+	wrs := []workspaceRow{}
+	for rows.Next() {
+		var r workspaceRow
+		if err := rows.Scan(&r.id, &r.name, &r.path); err != nil {
+			return nil, fmt.Errorf("scan row: %w", err)
+		}
+		wrs = append(wrs, r)
+	}
+	return wrs, rows.Err()
+}
+
+func queryInt(ctx context.Context, db querier, q string, args ...any) (int, error) {
+	var n int
+	start := time.Now()
+	err := db.QueryRowContext(ctx, q, args...).Scan(&n)
+	logQuery(ctx, q, start, err)
+	return n, err
+}
+
+func integrityCheck(ctx context.Context, db querier) ([]string, error) {
+	q := "pragma integrity_check"
+	start := time.Now()
+	rows, err := db.QueryContext(ctx, q)
+	logQuery(ctx, q, start, err)
+	if err != nil {
+		return nil, fmt.Errorf("exec query: %w", err)
+	}
+	defer rows.Close()
+	issues := []string{}
+	for rows.Next() {
+		var s string
+		if err := rows.Scan(&s); err != nil {
+			return nil, fmt.Errorf("scan row: %w", err)
+		}
+		if s != "ok" {
+			issues = append(issues, s)
+		}
+	}
+	return issues, rows.Err()
+}
+
+// Diagnose: reconciles the database with the workspaces found on disk
+func (s *SQLite) Diagnose(ctx context.Context, ws []workspaces.Workspace) ([]Problem, error) {
+	problems := []Problem{}
+	issues, err := integrityCheck(ctx, s.db)
+	if err != nil {
+		return nil, err
+	}
+	for _, issue := range issues {
+		problems = append(problems, Problem{Kind: PROBLEM_INTEGRITY, Detail: issue})
+	}
+	version, err := queryInt(ctx, s.db, "pragma user_version")
+	if err != nil {
+		return nil, err
+	}
+	if version != len(migrations) {
+		problems = append(problems, Problem{Kind: PROBLEM_SCHEMA, Detail: fmt.Sprintf("schema version %d, expected %d", version, len(migrations))})
+	}
+
+	rows, err := workspaceRows(ctx, s.db)
+	if err != nil {
+		return nil, err
+	}
+	recorded := map[string]bool{}
+	byName := map[string][]workspaceRow{}
+	for _, r := range rows {
+		recorded[r.path] = true
+		byName[r.name] = append(byName[r.name], r)
+	}
+	// workspaces on disk nothing points at yet, a missing row may have moved there. the id
+	// file of a moved workspace names its row.
+	unrecorded := map[string]*workspaces.Workspace{}
+	byId := map[string]*workspaces.Workspace{}
+	for i := range ws {
+		id, err := ws[i].ID()
+		if err == nil && id != "" {
+			byId[id] = &ws[i]
+		} else if err == nil && !recorded[ws[i].Path()] {
+			unrecorded[ws[i].DirEntry.Name()] = &ws[i]
+		}
+	}
+	// a missing workspace is never purged by default, it may only have been moved
+	for _, r := range rows {
+		if _, err := os.Stat(r.path); err == nil {
+			continue
+		}
+		p := Problem{Kind: PROBLEM_MISSING_PATH, WorkspaceId: r.id, Name: r.name, Path: r.path, Purgeable: true}
+		if w, ok := byId[r.id]; ok {
+			p.Fix, p.Target = FIX_RELINK, w
+		} else if w := archived(r.path); w != nil {
+			p.Fix, p.Target = FIX_RELINK, w
+		} else if w, ok := unrecorded[r.name]; ok {
+			p.Fix, p.Target, p.ByName = FIX_RELINK, w, true
+			delete(unrecorded, r.name)
+		} else if i := indexOfExisting(byName[r.name]); i >= 0 {
+			p.Fix, p.Into = FIX_MERGE, byName[r.name][i].id
+		}
+		problems = append(problems, p)
+	}
+	for i, r := range rows {
+		rs := byName[r.name]
+		if len(rs) < 2 || i > 0 && rows[i-1].name == r.name {
+			continue
+		}
+		name := r.name
+		paths := make([]string, len(rs))
+		for i := range rs {
+			paths[i] = rs[i].path
+		}
+		problems = append(problems, Problem{Kind: PROBLEM_DUPLICATE_NAME, Name: name, Path: rs[0].path, Detail: fmt.Sprintf("%d rows: %s", len(rs), strings.Join(paths, ", "))})
+	}
+
+	n, err := queryInt(ctx, s.db, "select count(*) from checkpoints where workspaceid not in (select id from workspaces)")
+	if err != nil {
+		return nil, err
+	}
+	if n > 0 {
+		problems = append(problems, Problem{Kind: PROBLEM_ORPHAN_CHECKPOINTS, Detail: fmt.Sprintf("%d checkpoints", n), Fix: FIX_PURGE})
+	}
+	n, err = queryInt(ctx, s.db, "select count(*) from tags where workspaceid not in (select id from workspaces)")
+	if err != nil {
+		return nil, err
+	}
+	if n > 0 {
+		problems = append(problems, Problem{Kind: PROBLEM_ORPHAN_TAGS, Detail: fmt.Sprintf("%d tags", n), Fix: FIX_PURGE})
+	}
+	n, err = queryInt(ctx, s.db, "select count(*) from activity where workspaceid not in (select id from workspaces)")
+	if err != nil {
+		return nil, err
+	}
+	if n > 0 {
+		problems = append(problems, Problem{Kind: PROBLEM_ORPHAN_ACTIVITY, Detail: fmt.Sprintf("%d activity entries", n), Fix: FIX_PURGE})
+	}
+	return problems, nil
+}
+
+// archived: the workspace once at p, when it was moved into the archive
+func archived(p string) *workspaces.Workspace {
+	dir := path.Join(path.Dir(p), workspaces.ARCHIVE_DIR)
+	info, err := os.Stat(path.Join(dir, path.Base(p)))
+	if err != nil || !info.IsDir() {
+		return nil
+	}
+	return &workspaces.Workspace{Parent: dir, DirEntry: fs.FileInfoToDirEntry(info)}
+}
+
+// indexOfExisting: the row whose directory still exists
+func indexOfExisting(rs []workspaceRow) int {
+	for i := range rs {
+		if _, err := os.Stat(rs[i].path); err == nil {
+			return i
+		}
+	}
+	return -1
+}
+
+// inTx: runs the statements in one transaction
+func inTx(ctx context.Context, db *sql.DB, stmts []string, args ...[]any) error {
+	return withTx(ctx, db, func(tx *sql.Tx) error {
+		for i, q := range stmts {
+			start := time.Now()
+			_, err := tx.ExecContext(ctx, q, args[i]...)
+			logQuery(ctx, q, start, err)
+			if err != nil {
+				return fmt.Errorf("exec query: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// Repair: applies the fix of the problem
+func (s *SQLite) Repair(ctx context.Context, p Problem) error {
+	switch {
+	case p.Fix == FIX_RELINK:
+		err := withTx(ctx, s.db, func(tx *sql.Tx) error { return relinkWorkspace(ctx, tx, p.WorkspaceId, p.Target) })
+		if err != nil {
+			return err
+		}
+		return p.Target.SetID(p.WorkspaceId)
+	case p.Fix == FIX_MERGE:
+		return inTx(ctx, s.db, []string{
+			"update checkpoints set workspaceid = ? where workspaceid = ?",
+			"update activity set workspaceid = ? where workspaceid = ?",
+			"insert or ignore into tags (workspaceid, name) select ?, name from tags where workspaceid = ?",
+			"delete from tags where workspaceid = ?",
+			"delete from workspaces where id = ?",
+		}, []any{p.Into, p.WorkspaceId}, []any{p.Into, p.WorkspaceId}, []any{p.Into, p.WorkspaceId}, []any{p.WorkspaceId}, []any{p.WorkspaceId})
+	case p.Fix == FIX_PURGE && p.Kind == PROBLEM_MISSING_PATH:
+		return inTx(ctx, s.db, []string{
+			"delete from checkpoints where workspaceid = ?",
+			"delete from tags where workspaceid = ?",
+			"delete from activity where workspaceid = ?",
+			"delete from workspaces where id = ?",
+		}, []any{p.WorkspaceId}, []any{p.WorkspaceId}, []any{p.WorkspaceId}, []any{p.WorkspaceId})
+	case p.Fix == FIX_PURGE && p.Kind == PROBLEM_ORPHAN_CHECKPOINTS:
+		return inTx(ctx, s.db, []string{"delete from checkpoints where workspaceid not in (select id from workspaces)"}, nil)
+	case p.Fix == FIX_PURGE && p.Kind == PROBLEM_ORPHAN_TAGS:
+		return inTx(ctx, s.db, []string{"delete from tags where workspaceid not in (select id from workspaces)"}, nil)
+	case p.Fix == FIX_PURGE && p.Kind == PROBLEM_ORPHAN_ACTIVITY:
+		return inTx(ctx, s.db, []string{"delete from activity where workspaceid not in (select id from workspaces)"}, nil)
+	}
+	return fmt.Errorf("%s: no automatic fix", p.Kind)
+}
@@ -0,0 +1,462 @@
+package db
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"workspaces-cli/pkg/gitinfo"
+	"workspaces-cli/pkg/workspaces"
+
+	"github.com/google/uuid"
+)
+
+const (
+	WORKSPACE_FILE  string = "workspace.json"
+	CHECKPOINTS_DIR string = "checkpoints"
+	ACTIVITY_FILE   string = "activity.jsonl"
+)
+
+// Files: store made of plain files that can be read, edited and versioned by hand. each
+// workspace gets a directory named after its id:
+//
+//	<id>/workspace.json              id, name, path and tags
+//	<id>/checkpoints/<date>-<id>.md  one markdown file per checkpoint, with front matter
+//	<id>/activity.jsonl              one json object per line
+type Files struct {
+	mu  sync.Mutex
+	dir string
+}
+
+// fileRecord: the content of WORKSPACE_FILE
+type fileRecord struct {
+	Id   string   `json:"id"`
+	Name string   `json:"name"`
+	Path string   `json:"path"`
+	Tags []string `json:"tags"`
+}
+
+type fileActivity struct {
+	Kind string    `json:"kind"`
+	Date time.Time `json:"date"`
+}
+
+func OpenFiles(dir string) (*Files, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &Files{dir: dir}, nil
+}
+
+// writeFile: replaces the file in one step, readers never see it half written
+func writeFile(file string, data []byte) error {
+	tmp := file + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, file)
+}
+
+func (s *Files) readRecord(wid string) (*fileRecord, error) {
+	data, err := os.ReadFile(path.Join(s.dir, wid, WORKSPACE_FILE))
+	if err != nil {
+		return nil, err
+	}
+	var r fileRecord
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, fmt.Errorf("%s: %w", path.Join(wid, WORKSPACE_FILE), err)
+	}
+	return &r, nil
+}
+
+func (s *Files) writeRecord(r *fileRecord) error {
+	if err := os.MkdirAll(path.Join(s.dir, r.Id), 0o755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFile(path.Join(s.dir, r.Id, WORKSPACE_FILE), append(data, '\n'))
+}
+
+// records: every workspace in the store, in directory order
+func (s *Files) records() ([]*fileRecord, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+	records := make([]*fileRecord, 0, len(entries))
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		r, err := s.readRecord(e.Name())
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		records = append(records, r)
+	}
+	return records, nil
+}
+
+// record: the workspace as recorded, by its id file first and then by path, nil when it
+// was never recorded. it only reads, records of moved workspaces are re-linked by relink.
+func (s *Files) record(w *workspaces.Workspace) (*fileRecord, error) {
+	wid, err := w.ID()
+	if err != nil {
+		return nil, fmt.Errorf("read id file: %w", err)
+	}
+	if wid != "" {
+		r, err := s.readRecord(wid)
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return r, err
+	}
+	records, err := s.records()
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range records {
+		if r.Path == w.Path() {
+			return r, nil
+		}
+	}
+	return nil, nil
+}
+
+// relink: points the record at the workspace when it was moved or renamed
+func (s *Files) relink(r *fileRecord, w *workspaces.Workspace) error {
+	if r.Name == w.DirEntry.Name() && r.Path == w.Path() {
+		return nil
+	}
+	r.Name, r.Path = w.DirEntry.Name(), w.Path()
+	return s.writeRecord(r)
+}
+
+func (s *Files) MoveWorkspace(ctx context.Context, from string, to workspaces.Workspace) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, err := s.record(&to)
+	if err != nil {
+		return err
+	}
+	if r == nil {
+		records, err := s.records()
+		if err != nil {
+			return err
+		}
+		if i := slices.IndexFunc(records, func(r *fileRecord) bool { return r.Path == from }); i >= 0 {
+			r = records[i]
+		}
+	}
+	if r == nil {
+		return nil
+	}
+	return s.relink(r, &to)
+}
+
+// ensureRecord: the workspace record, created if it is new. like the sqlite store it
+// leaves an id file in the workspace.
+func (s *Files) ensureRecord(ctx context.Context, w *workspaces.Workspace) (*fileRecord, error) {
+	wid, err := w.ID()
+	if err != nil {
+		return nil, fmt.Errorf("read id file: %w", err)
+	}
+	r, err := s.record(w)
+	if err != nil {
+		return nil, err
+	}
+	if r != nil {
+		if wid != "" {
+			if err := w.ExcludeID(); err != nil {
+				slog.DebugContext(ctx, "exclude id file", "workspace", w.Path(), "error", err)
+			}
+		}
+		return r, s.relink(r, w)
+	}
+	written := wid != ""
+	if wid == "" {
+		wid = uuid.New().String()
+	}
+	r = &fileRecord{Id: wid, Name: w.DirEntry.Name(), Path: w.Path(), Tags: []string{}}
+	if err := s.writeRecord(r); err != nil {
+		return nil, err
+	}
+	if !written {
+		if err := w.SetID(wid); err != nil {
+			slog.DebugContext(ctx, "write id file", "workspace", w.Path(), "error", err)
+		}
+	}
+	return r, nil
+}
+
+func (s *Files) Workspaces(ctx context.Context) ([]Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	records, err := s.records()
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Record, 0, len(records))
+	for _, r := range records {
+		out = append(out, Record{Id: r.Id, Name: r.Name, Path: r.Path, Tags: r.Tags})
+	}
+	slices.SortFunc(out, func(a, b Record) int { return strings.Compare(a.Name, b.Name) })
+	return out, nil
+}
+
+// checkpointFile: markdown with the id, date and git state in front matter and the value as body
+func checkpointFile(c Checkpoint) []byte {
+	return []byte(fmt.Sprintf("---\nid: %s\ndate: %s\n%s---\n\n%s\n", c.Id, c.Date.UTC().Format(time.RFC3339Nano), c.Git.FrontMatter(), c.Value))
+}
+
+func parseCheckpointFile(wid string, data []byte) (Checkpoint, error) {
+	c := Checkpoint{WorkspaceId: wid}
+	rest, ok := strings.CutPrefix(string(data), "---\n")
+	if !ok {
+		return c, fmt.Errorf("missing front matter")
+	}
+	front, body, ok := strings.Cut(rest, "---\n")
+	if !ok {
+		return c, fmt.Errorf("unterminated front matter")
+	}
+	for _, line := range strings.Split(front, "\n") {
+		k, v, _ := strings.Cut(line, ":")
+		if c.Git.ParseFrontMatter(strings.TrimSpace(k), strings.TrimSpace(v)) {
+			continue
+		}
+		switch strings.TrimSpace(k) {
+		case "id":
+			c.Id = strings.TrimSpace(v)
+		case "date":
+			date, err := time.Parse(time.RFC3339Nano, strings.TrimSpace(v))
+			if err != nil {
+				return c, fmt.Errorf("date: %w", err)
+			}
+			c.Date = date
+		}
+	}
+	c.Value = strings.TrimSpace(body)
+	return c, nil
+}
+
+func (s *Files) InsertCheckpoint(ctx context.Context, w workspaces.Workspace, data []byte, git gitinfo.State) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, err := s.ensureRecord(ctx, &w)
+	if err != nil {
+		return err
+	}
+	return s.writeCheckpoint(r.Id, Checkpoint{Id: uuid.New().String(), Value: string(data), Date: time.Now(), Git: git})
+}
+
+func (s *Files) ImportCheckpoint(ctx context.Context, w workspaces.Workspace, c Checkpoint) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, err := s.ensureRecord(ctx, &w)
+	if err != nil {
+		return err
+	}
+	taken := false
+	if c.Id != "" {
+		if taken, err = s.hasCheckpoint(c.Id); err != nil {
+			return err
+		}
+	}
+	if c.Id == "" || taken {
+		c.Id = uuid.New().String()
+	}
+	return s.writeCheckpoint(r.Id, c)
+}
+
+// hasCheckpoint: whether any workspace has a checkpoint with the id
+func (s *Files) hasCheckpoint(id string) (bool, error) {
+	records, err := s.records()
+	if err != nil {
+		return false, err
+	}
+	for _, r := range records {
+		names, err := s.checkpointNames(r.Id)
+		if err != nil {
+			return false, err
+		}
+		checkpoints, err := s.readCheckpoints(r.Id, names)
+		if err != nil {
+			return false, err
+		}
+		if slices.ContainsFunc(checkpoints, func(c Checkpoint) bool { return c.Id == id }) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+var unsafeNameChars = regexp.MustCompile(`[^A-Za-z0-9_-]+`)
+
+// checkpointName: sortable by name, the id keeps checkpoints of the same instant apart.
+// ids may come from imports, only their safe characters end up in the name.
+func checkpointName(c Checkpoint) string {
+	return fmt.Sprintf("%s-%s.md", c.Date.UTC().Format("20060102T150405.000000000Z"), unsafeNameChars.ReplaceAllString(c.Id, "_"))
+}
+
+func (s *Files) writeCheckpoint(wid string, c Checkpoint) error {
+	c.WorkspaceId, c.Value = wid, strings.TrimSpace(c.Value)
+	dir := path.Join(s.dir, wid, CHECKPOINTS_DIR)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	return writeFile(path.Join(dir, checkpointName(c)), checkpointFile(c))
+}
+
+func (s *Files) GetCheckpoints(ctx context.Context, w workspaces.Workspace, limit int) ([]Checkpoint, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, err := s.record(&w)
+	if err != nil || r == nil {
+		return nil, err
+	}
+	names, err := s.checkpointNames(r.Id)
+	if err != nil {
+		return nil, err
+	}
+	return s.readCheckpoints(r.Id, newest(names, limit))
+}
+
+func (s *Files) Checkpoints(ctx context.Context, wid string) ([]Checkpoint, error) {
+	// the id names a directory of the store, it is never taken as a path
+	if err := uuid.Validate(wid); err != nil {
+		return nil, fmt.Errorf("workspace id '%s': %w", wid, err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	names, err := s.checkpointNames(wid)
+	if err != nil {
+		return nil, err
+	}
+	return s.readCheckpoints(wid, names)
+}
+
+// checkpointNames: the checkpoint files of the workspace, oldest first
+func (s *Files) checkpointNames(wid string) ([]string, error) {
+	entries, err := os.ReadDir(path.Join(s.dir, wid, CHECKPOINTS_DIR))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	names := []string{}
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasSuffix(e.Name(), ".md") {
+			names = append(names, e.Name())
+		}
+	}
+	slices.Sort(names)
+	return names, nil
+}
+
+func (s *Files) readCheckpoints(wid string, names []string) ([]Checkpoint, error) {
+	checkpoints := make([]Checkpoint, 0, len(names))
+	for _, name := range names {
+		data, err := os.ReadFile(path.Join(s.dir, wid, CHECKPOINTS_DIR, name))
+		if err != nil {
+			return nil, err
+		}
+		c, err := parseCheckpointFile(wid, data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		checkpoints = append(checkpoints, c)
+	}
+	return checkpoints, nil
+}
+
+func (s *Files) AddTag(ctx context.Context, w workspaces.Workspace, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, err := s.ensureRecord(ctx, &w)
+	if err != nil {
+		return err
+	}
+	if slices.Contains(r.Tags, name) {
+		return nil
+	}
+	r.Tags = append(r.Tags, name)
+	slices.Sort(r.Tags)
+	return s.writeRecord(r)
+}
+
+func (s *Files) GetTags(ctx context.Context, w workspaces.Workspace) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, err := s.record(&w)
+	if err != nil || r == nil {
+		return nil, err
+	}
+	return r.Tags, nil
+}
+
+func (s *Files) RecordActivity(ctx context.Context, w workspaces.Workspace, kind string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, err := s.ensureRecord(ctx, &w)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(fileActivity{Kind: kind, Date: time.Now().UTC()})
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path.Join(s.dir, r.Id, ACTIVITY_FILE), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(append(data, '\n'))
+	return errors.Join(err, f.Close())
+}
+
+func (s *Files) GetActivity(ctx context.Context, w workspaces.Workspace, limit int) ([]Activity, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, err := s.record(&w)
+	if err != nil || r == nil {
+		return nil, err
+	}
+	f, err := os.Open(path.Join(s.dir, r.Id, ACTIVITY_FILE))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	activity := []Activity{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var a fileActivity
+		if err := json.Unmarshal(scanner.Bytes(), &a); err != nil {
+			return nil, fmt.Errorf("%s: %w", ACTIVITY_FILE, err)
+		}
+		activity = append(activity, Activity{WorkspaceId: r.Id, Kind: a.Kind, Date: a.Date})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return newest(activity, limit), nil
+}
+
+func (s *Files) Close() error {
+	return nil
+}
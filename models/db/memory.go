@@ -0,0 +1,201 @@
+package db
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"workspaces-cli/pkg/gitinfo"
+	"workspaces-cli/pkg/workspaces"
+
+	"github.com/google/uuid"
+)
+
+// Memory: store that keeps everything in the process, for tests and throwaway sessions.
+// it reads id files but never writes them.
+type Memory struct {
+	mu          sync.Mutex
+	records     map[string]*Record
+	checkpoints map[string][]Checkpoint
+	tags        map[string][]string
+	activity    map[string][]Activity
+}
+
+func NewMemory() *Memory {
+	return &Memory{
+		records:     map[string]*Record{},
+		checkpoints: map[string][]Checkpoint{},
+		tags:        map[string][]string{},
+		activity:    map[string][]Activity{},
+	}
+}
+
+// id: id of the recorded workspace, by its id file first and then by path. the record is
+// re-linked when the workspace moved and created when create is set.
+func (s *Memory) id(w *workspaces.Workspace, create bool) (string, error) {
+	wid, err := w.ID()
+	if err != nil {
+		return "", err
+	}
+	if wid == "" {
+		for _, r := range s.records {
+			if r.Path == w.Path() {
+				return r.Id, nil
+			}
+		}
+	}
+	if r, ok := s.records[wid]; ok {
+		r.Name, r.Path = w.DirEntry.Name(), w.Path()
+		return wid, nil
+	}
+	if !create {
+		return "", nil
+	}
+	if wid == "" {
+		wid = uuid.New().String()
+	}
+	s.records[wid] = &Record{Id: wid, Name: w.DirEntry.Name(), Path: w.Path()}
+	return wid, nil
+}
+
+func (s *Memory) Workspaces(ctx context.Context) ([]Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	records := make([]Record, 0, len(s.records))
+	for _, r := range s.records {
+		rec := *r
+		rec.Tags = slices.Clone(s.tags[r.Id])
+		records = append(records, rec)
+	}
+	slices.SortFunc(records, func(a, b Record) int { return strings.Compare(a.Name, b.Name) })
+	return records, nil
+}
+
+func (s *Memory) MoveWorkspace(ctx context.Context, from string, to workspaces.Workspace) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if wid, err := s.id(&to, false); err != nil || wid != "" {
+		return err
+	}
+	for _, r := range s.records {
+		if r.Path == from {
+			r.Name, r.Path = to.DirEntry.Name(), to.Path()
+		}
+	}
+	return nil
+}
+
+func (s *Memory) InsertCheckpoint(ctx context.Context, w workspaces.Workspace, data []byte, git gitinfo.State) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	wid, err := s.id(&w, true)
+	if err != nil {
+		return err
+	}
+	s.checkpoints[wid] = append(s.checkpoints[wid], Checkpoint{
+		Id:          uuid.New().String(),
+		WorkspaceId: wid,
+		Value:       strings.TrimSpace(string(data)),
+		Date:        time.Now(),
+		Git:         git,
+	})
+	return nil
+}
+
+func (s *Memory) ImportCheckpoint(ctx context.Context, w workspaces.Workspace, c Checkpoint) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	wid, err := s.id(&w, true)
+	if err != nil {
+		return err
+	}
+	for _, cs := range s.checkpoints {
+		if slices.ContainsFunc(cs, func(o Checkpoint) bool { return o.Id == c.Id }) {
+			c.Id = ""
+		}
+	}
+	if c.Id == "" {
+		c.Id = uuid.New().String()
+	}
+	c.WorkspaceId, c.Value = wid, strings.TrimSpace(c.Value)
+	s.checkpoints[wid] = append(s.checkpoints[wid], c)
+	// kept oldest first, imports may be older than what is there
+	slices.SortStableFunc(s.checkpoints[wid], func(a, b Checkpoint) int { return a.Date.Compare(b.Date) })
+	return nil
+}
+
+// newest: the last limit items of s, newest first
+func newest[T any](s []T, limit int) []T {
+	n := min(limit, len(s))
+	out := make([]T, 0, n)
+	for i := len(s) - 1; i >= len(s)-n; i-- {
+		out = append(out, s[i])
+	}
+	return out
+}
+
+func (s *Memory) GetCheckpoints(ctx context.Context, w workspaces.Workspace, limit int) ([]Checkpoint, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	wid, err := s.id(&w, false)
+	if err != nil || wid == "" {
+		return nil, err
+	}
+	return newest(s.checkpoints[wid], limit), nil
+}
+
+func (s *Memory) Checkpoints(ctx context.Context, wid string) ([]Checkpoint, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.checkpoints[wid]), nil
+}
+
+func (s *Memory) AddTag(ctx context.Context, w workspaces.Workspace, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	wid, err := s.id(&w, true)
+	if err != nil {
+		return err
+	}
+	if !slices.Contains(s.tags[wid], name) {
+		s.tags[wid] = append(s.tags[wid], name)
+		slices.Sort(s.tags[wid])
+	}
+	return nil
+}
+
+func (s *Memory) GetTags(ctx context.Context, w workspaces.Workspace) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	wid, err := s.id(&w, false)
+	if err != nil || wid == "" {
+		return nil, err
+	}
+	return slices.Clone(s.tags[wid]), nil
+}
+
+func (s *Memory) RecordActivity(ctx context.Context, w workspaces.Workspace, kind string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	wid, err := s.id(&w, true)
+	if err != nil {
+		return err
+	}
+	s.activity[wid] = append(s.activity[wid], Activity{WorkspaceId: wid, Kind: kind, Date: time.Now()})
+	return nil
+}
+
+func (s *Memory) GetActivity(ctx context.Context, w workspaces.Workspace, limit int) ([]Activity, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	wid, err := s.id(&w, false)
+	if err != nil || wid == "" {
+		return nil, err
+	}
+	return newest(s.activity[wid], limit), nil
+}
+
+func (s *Memory) Close() error {
+	return nil
+}
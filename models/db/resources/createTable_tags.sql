@@ -0,0 +1 @@
+create table if not exists tags(workspaceid text, name text, primary key (workspaceid, name));
\ No newline at end of file
@@ -1 +1 @@
-create table if not exists workspaces(id text, name text primary key, path text);
\ No newline at end of file
+create table if not exists workspaces(id text primary key, name text, path text);
\ No newline at end of file
@@ -0,0 +1,4 @@
+create table workspaces_new(id text primary key, name text, path text);
+insert or ignore into workspaces_new select id, name, path from workspaces;
+drop table workspaces;
+alter table workspaces_new rename to workspaces;
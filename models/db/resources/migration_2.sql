@@ -0,0 +1,2 @@
+create table activity(workspaceid text, kind text, date integer);
+create index activity_workspaceid on activity(workspaceid, date);
@@ -0,0 +1,4 @@
+alter table checkpoints add column branch text not null default '';
+alter table checkpoints add column head text not null default '';
+alter table checkpoints add column dirty integer not null default 0;
+alter table checkpoints add column diffstat text not null default '';
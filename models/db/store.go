@@ -0,0 +1,70 @@
+package db
+
+import (
+	"context"
+	"math"
+	"time"
+	"workspaces-cli/pkg/gitinfo"
+	"workspaces-cli/pkg/workspaces"
+)
+
+const (
+	STORE_SQLITE string = "sqlite" // the default, one database file in the workspaces root
+	STORE_FILES  string = "files"  // plain json and markdown files, one directory per workspace
+	STORE_MEMORY string = "memory" // nothing is kept once the process exits
+
+	SQLITE_FILE string = "workspaces.sql"
+	NO_LIMIT    int    = math.MaxInt32 // for GetCheckpoints and GetActivity
+
+	ACTIVITY_OPEN       string = "open"
+	ACTIVITY_COMMAND    string = "command"
+	ACTIVITY_CHECKPOINT string = "checkpoint"
+)
+
+// Store: where workspaces, their checkpoints, tags and activity are kept. workspaces are
+// recorded the first time something is stored for them. ImportCheckpoint keeps the id and
+// date of the checkpoint, a new id is made when it is empty or taken. MoveWorkspace points
+// the workspace recorded at from at its new place, workspaces never recorded are skipped.
+type Store interface {
+	Workspaces(ctx context.Context) ([]Record, error)
+	Checkpoints(ctx context.Context, wid string) ([]Checkpoint, error)
+	InsertCheckpoint(ctx context.Context, w workspaces.Workspace, data []byte, git gitinfo.State) error
+	ImportCheckpoint(ctx context.Context, w workspaces.Workspace, c Checkpoint) error
+	GetCheckpoints(ctx context.Context, w workspaces.Workspace, limit int) ([]Checkpoint, error)
+	AddTag(ctx context.Context, w workspaces.Workspace, name string) error
+	GetTags(ctx context.Context, w workspaces.Workspace) ([]string, error)
+	RecordActivity(ctx context.Context, w workspaces.Workspace, kind string) error
+	GetActivity(ctx context.Context, w workspaces.Workspace, limit int) ([]Activity, error)
+	MoveWorkspace(ctx context.Context, from string, to workspaces.Workspace) error
+	Close() error
+}
+
+// Doctor: stores that can check themselves against the workspaces on disk. repairs may
+// delete rows, callers take BackupBeforeRepair once before a batch of them.
+type Doctor interface {
+	Diagnose(ctx context.Context, ws []workspaces.Workspace) ([]Problem, error)
+	BackupBeforeRepair(ctx context.Context) (Backup, error)
+	Repair(ctx context.Context, p Problem) error
+}
+
+// Record: a workspace as the store knows it
+type Record struct {
+	Id   string
+	Name string
+	Path string
+	Tags []string
+}
+
+type Checkpoint struct {
+	Id          string
+	WorkspaceId string
+	Value       string
+	Date        time.Time
+	Git         gitinfo.State // the code of the workspace when it was written
+}
+
+type Activity struct {
+	WorkspaceId string
+	Kind        string
+	Date        time.Time
+}
@@ -0,0 +1,182 @@
+package db
+
+import (
+	"context"
+	"os"
+	"path"
+	"slices"
+	"testing"
+	"time"
+	"workspaces-cli/pkg/gitinfo"
+	"workspaces-cli/pkg/workspaces"
+)
+
+// backends: a fresh store of every kind, closed with the test
+func backends(t *testing.T) map[string]Store {
+	t.Helper()
+	ctx := context.Background()
+	sqlite, err := OpenSQLite(ctx, path.Join(t.TempDir(), SQLITE_FILE), Backups{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	files, err := OpenFiles(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	stores := map[string]Store{STORE_SQLITE: sqlite, STORE_FILES: files, STORE_MEMORY: NewMemory()}
+	t.Cleanup(func() {
+		for _, s := range stores {
+			s.Close()
+		}
+	})
+	return stores
+}
+
+// testWorkspaces: workspaces of a fresh root, by name
+func testWorkspaces(t *testing.T, names ...string) []workspaces.Workspace {
+	t.Helper()
+	root := t.TempDir()
+	for _, n := range names {
+		if err := os.Mkdir(path.Join(root, n), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ws, err := workspaces.Load(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ws
+}
+
+func values(cs []Checkpoint) []string {
+	vs := make([]string, len(cs))
+	for i := range cs {
+		vs[i] = cs[i].Value
+	}
+	return vs
+}
+
+func TestStoreInsertAndGet(t *testing.T) {
+	ctx := context.Background()
+	for name, s := range backends(t) {
+		t.Run(name, func(t *testing.T) {
+			ws := testWorkspaces(t, "a", "b")
+			if cs, err := s.GetCheckpoints(ctx, ws[0], NO_LIMIT); err != nil || len(cs) != 0 {
+				t.Fatalf("unrecorded workspace: %v, %v", cs, err)
+			}
+			git := gitinfo.State{Branch: "main", Head: "1a2b3c4", Dirty: 2, DiffStat: "1 file changed, 1 insertion(+)"}
+			for _, v := range []string{"first", "second", "third"} {
+				if err := s.InsertCheckpoint(ctx, ws[0], []byte(" "+v+"\n"), git); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := s.InsertCheckpoint(ctx, ws[1], []byte("other"), gitinfo.State{}); err != nil {
+				t.Fatal(err)
+			}
+			cs, err := s.GetCheckpoints(ctx, ws[0], 2)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := values(cs); !slices.Equal(got, []string{"third", "second"}) {
+				t.Errorf("newest first: %v", got)
+			}
+			if cs[0].Git != git {
+				t.Errorf("git state: %+v", cs[0].Git)
+			}
+			all, err := s.Checkpoints(ctx, cs[0].WorkspaceId)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := values(all); !slices.Equal(got, []string{"first", "second", "third"}) {
+				t.Errorf("oldest first: %v", got)
+			}
+			other, err := s.GetCheckpoints(ctx, ws[1], NO_LIMIT)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := values(other); !slices.Equal(got, []string{"other"}) || !other[0].Git.IsZero() {
+				t.Errorf("other workspace: %v %+v", got, other)
+			}
+		})
+	}
+}
+
+func TestStoreImport(t *testing.T) {
+	ctx := context.Background()
+	date := time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC)
+	for name, s := range backends(t) {
+		t.Run(name, func(t *testing.T) {
+			ws := testWorkspaces(t, "a", "b")
+			imports := []struct {
+				w  workspaces.Workspace
+				c  Checkpoint
+				id string // kept id, empty when a new one is expected
+			}{
+				{ws[0], Checkpoint{Id: "4b8c0f4e-2f1d-4c57-9a43-6f0f7e1d2c3b", Value: "kept", Date: date}, "4b8c0f4e-2f1d-4c57-9a43-6f0f7e1d2c3b"},
+				{ws[0], Checkpoint{Id: "a", Value: "short", Date: date.Add(time.Minute)}, "a"},
+				{ws[0], Checkpoint{Id: "../x", Value: "unsafe", Date: date.Add(2 * time.Minute)}, "../x"},
+				{ws[0], Checkpoint{Value: "empty", Date: date.Add(3 * time.Minute)}, ""},
+				{ws[0], Checkpoint{Id: "a", Value: "taken", Date: date.Add(time.Minute)}, ""},
+				{ws[1], Checkpoint{Id: "a", Value: "taken elsewhere", Date: date}, ""},
+			}
+			for _, i := range imports {
+				if err := s.ImportCheckpoint(ctx, i.w, i.c); err != nil {
+					t.Fatalf("%s: %v", i.c.Value, err)
+				}
+			}
+			cs, err := s.GetCheckpoints(ctx, ws[0], NO_LIMIT)
+			if err != nil {
+				t.Fatal(err)
+			}
+			other, err := s.GetCheckpoints(ctx, ws[1], NO_LIMIT)
+			if err != nil {
+				t.Fatal(err)
+			}
+			cs = append(cs, other...)
+			if len(cs) != len(imports) {
+				t.Fatalf("%d checkpoints, expected %d: %v", len(cs), len(imports), values(cs))
+			}
+			seen := map[string]bool{}
+			for _, i := range imports {
+				j := slices.IndexFunc(cs, func(c Checkpoint) bool { return c.Value == i.c.Value })
+				if j < 0 {
+					t.Errorf("%s: missing", i.c.Value)
+					continue
+				}
+				c := cs[j]
+				if i.id != "" && c.Id != i.id {
+					t.Errorf("%s: id %s, expected %s", i.c.Value, c.Id, i.id)
+				}
+				if c.Id == "" || seen[c.Id] {
+					t.Errorf("%s: id '%s' empty or taken", i.c.Value, c.Id)
+				}
+				seen[c.Id] = true
+				if !c.Date.Equal(i.c.Date) {
+					t.Errorf("%s: date %s, expected %s", i.c.Value, c.Date, i.c.Date)
+				}
+			}
+		})
+	}
+}
+
+func TestStoreUnsafeIdFile(t *testing.T) {
+	ctx := context.Background()
+	for name, s := range backends(t) {
+		t.Run(name, func(t *testing.T) {
+			ws := testWorkspaces(t, "a")
+			root := path.Dir(ws[0].Path())
+			if err := os.WriteFile(path.Join(ws[0].Path(), workspaces.ID_FILE), []byte("../..\n"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := ws[0].ID(); err == nil {
+				t.Fatal("an id file naming a path should fail")
+			}
+			if err := s.InsertCheckpoint(ctx, ws[0], []byte("note"), gitinfo.State{}); err == nil {
+				t.Error("insert should fail")
+			}
+			if _, err := os.Stat(path.Join(path.Dir(root), WORKSPACE_FILE)); err == nil {
+				t.Error("workspace.json written outside of the store")
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+const (
+	BUSY_TIMEOUT time.Duration = 5 * time.Second // sqlite waits this long for a lock itself
+	MAX_RETRIES  int           = 4               // then the whole transaction is retried
+	RETRY_DELAY  time.Duration = 50 * time.Millisecond
+)
+
+// querier: what the queries need, either the database or a transaction
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// dsn: WAL lets readers in other terminals go on while one writes, and write transactions
+// take their lock upfront so two writers can't deadlock upgrading a read lock
+func dsn(file string) string {
+	return fmt.Sprintf("file:%s?_journal_mode=WAL&_busy_timeout=%d&_txlock=immediate", file, BUSY_TIMEOUT.Milliseconds())
+}
+
+func isBusy(err error) bool {
+	var e sqlite3.Error
+	return errors.As(err, &e) && (e.Code == sqlite3.ErrBusy || e.Code == sqlite3.ErrLocked)
+}
+
+// withRetry: runs fn again while the database is locked by another process, backing off
+// between attempts
+func withRetry(ctx context.Context, fn func() error) error {
+	for attempt := 0; ; attempt++ {
+		err := fn()
+		if !isBusy(err) || attempt == MAX_RETRIES {
+			return err
+		}
+		slog.DebugContext(ctx, "database busy, retrying", "attempt", attempt+1, "error", err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(RETRY_DELAY << attempt):
+		}
+	}
+}
+
+// withTx: runs fn in a transaction, committed when fn succeeds. a busy database retries
+// the whole transaction.
+func withTx(ctx context.Context, db *sql.DB, fn func(tx *sql.Tx) error) error {
+	return withRetry(ctx, func() error {
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		if err := fn(tx); err != nil {
+			return errors.Join(err, tx.Rollback())
+		}
+		return tx.Commit()
+	})
+}
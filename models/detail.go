@@ -0,0 +1,189 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+	"workspaces-cli/models/db"
+	"workspaces-cli/pkg/gitinfo"
+	"workspaces-cli/pkg/theme"
+	"workspaces-cli/pkg/workspaces"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+const (
+	DETAIL_README_LINES int           = 3
+	DETAIL_COMMITS      int           = 3
+	DETAIL_CHECKPOINTS  int           = 3
+	DETAIL_TTL          time.Duration = 30 * time.Second // branch, commits and checkpoints change behind our back
+)
+
+// detail: rendered details of a workspace and when they were loaded
+type detail struct {
+	text string
+	at   time.Time
+}
+
+// detailLoad: the details loading in the background. ids only grow, a result carrying
+// an older one is dropped.
+type detailLoad struct {
+	id     int
+	path   string // empty when nothing loads
+	cancel context.CancelFunc
+}
+
+func formatSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for v := n / unit; v >= unit; v /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+func detailLabel(s string) string {
+	return theme.Render(theme.LABEL, fmt.Sprintf("%-12s", s))
+}
+
+// generateDetailString: gathers everything shown in the detail pane. this walks the
+// workspace and shells out to git, so it only ever runs inside a tea.Cmd.
+func generateDetailString(ctx context.Context, store db.Store, w workspaces.Workspace) string {
+	b := strings.Builder{}
+	b.WriteString(theme.Render(theme.TITLE, w.DirEntry.Name()) + "\n")
+	if readme, err := w.ReadmeExcerpt(DETAIL_README_LINES); err == nil && readme != "" {
+		for _, l := range strings.Split(readme, "\n") {
+			b.WriteString("  " + l + "\n")
+		}
+	}
+	langs, size, err := w.Languages(ctx)
+	if err != nil {
+		b.WriteString(detailLabel("languages") + err.Error() + "\n")
+	} else if len(langs) > 0 {
+		b.WriteString(detailLabel("languages") + strings.Join(langs, ", ") + "\n")
+	}
+	b.WriteString(detailLabel("size") + formatSize(size) + "\n")
+	if gitinfo.IsRepository(w.Path()) {
+		if branch, err := gitinfo.Branch(w.Path()); err == nil {
+			b.WriteString(detailLabel("branch") + branch + "\n")
+		}
+		if commits, err := gitinfo.RecentCommits(w.Path(), DETAIL_COMMITS); err == nil {
+			for i := range commits {
+				label := ""
+				if i == 0 {
+					label = "commits"
+				}
+				b.WriteString(detailLabel(label) + commits[i] + "\n")
+			}
+		}
+	}
+	if tags, err := store.GetTags(ctx, w); err != nil {
+		b.WriteString(detailLabel("tags") + err.Error() + "\n")
+	} else if len(tags) > 0 {
+		b.WriteString(detailLabel("tags") + strings.Join(tags, ", ") + "\n")
+	}
+	if activity, err := store.GetActivity(ctx, w, 1); err != nil {
+		b.WriteString(detailLabel("last used") + err.Error() + "\n")
+	} else if len(activity) > 0 {
+		b.WriteString(detailLabel("last used") + modtimeColorize(activity[0].Date) + " " + activity[0].Kind + "\n")
+	}
+	checkpoints, err := store.GetCheckpoints(ctx, w, DETAIL_CHECKPOINTS)
+	if err != nil {
+		b.WriteString(detailLabel("checkpoints") + err.Error() + "\n")
+	}
+	for i := range checkpoints {
+		label := ""
+		if i == 0 {
+			label = "checkpoints"
+		}
+		value, _, _ := strings.Cut(checkpoints[i].Value, "\n")
+		b.WriteString(detailLabel(label) + modtimeColorize(checkpoints[i].Date) + " " + value + "\n")
+		if git := checkpoints[i].Git; !git.IsZero() {
+			b.WriteString(detailLabel("") + theme.Render(theme.FOOTER, git.String()) + "\n")
+		}
+	}
+	return b.String()
+}
+
+// selectedWorkspace: the workspace under the cursor for the active mode
+func (m *Application) selectedWorkspace() *workspaces.Workspace {
+	if m.isFilterActive {
+		if m.filterCursor < len(m.filteredWorkspaces) {
+			return m.filteredWorkspaces[m.filterCursor]
+		}
+		return nil
+	}
+	if m.cursor < len(m.workspaces) {
+		return &m.workspaces[m.cursor]
+	}
+	return nil
+}
+
+func (m *Application) toggleDetail() {
+	m.isDetailActive = !m.isDetailActive
+	m.detailPane = ""
+	m.cancelDetailLoad()
+	// showing the pane again is the natural way to ask for fresh details
+	clear(m.details)
+}
+
+// cancelDetailLoad: stops the load in the background, its result is dropped
+func (m *Application) cancelDetailLoad() {
+	if m.detailLoad.cancel != nil {
+		m.detailLoad.cancel()
+	}
+	m.detailLoad = detailLoad{id: m.detailLoad.id}
+}
+
+// detailLoader: returns the cmd refreshing the detail pane for the selected workspace.
+// cached details are rendered right away and reloaded in the background once older
+// than DETAIL_TTL, anything else is loaded in the background. a load for a workspace
+// that is no longer selected is cancelled, scrolling through the list would otherwise
+// pile up walks of every workspace passed on the way.
+func (m *Application) detailLoader(ctx context.Context) tea.Cmd {
+	w := m.selectedWorkspace()
+	if !m.isDetailActive || w == nil {
+		m.cancelDetailLoad()
+		return nil
+	}
+	if m.detailLoad.path != w.Path() {
+		m.cancelDetailLoad()
+	}
+	d, ok := m.details[w.Path()]
+	if ok {
+		m.detailPane = d.text
+		if time.Since(d.at) < DETAIL_TTL {
+			return nil
+		}
+	} else {
+		m.detailPane = theme.Render(theme.FOOTER, "loading details...") + "\n"
+	}
+	if m.detailLoad.path == w.Path() {
+		// already loading, the stale text stays up until it is done
+		return nil
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	m.detailLoad = detailLoad{id: m.detailLoad.id + 1, path: w.Path(), cancel: cancel}
+	load, ws := m.detailLoad.id, *w
+	return func() tea.Msg {
+		defer cancel()
+		text := generateDetailString(ctx, m.store, ws)
+		if ctx.Err() != nil {
+			return nil
+		}
+		return renderdetailcmd{load: load, path: ws.Path(), detail: text}
+	}
+}
+
+func (m *Application) invalidateDetail(w workspaces.Workspace) {
+	delete(m.details, w.Path())
+	if m.detailLoad.path == w.Path() {
+		// it may have read what just changed
+		m.cancelDetailLoad()
+	}
+}
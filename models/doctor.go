@@ -0,0 +1,151 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"workspaces-cli/models/db"
+	"workspaces-cli/models/modes"
+	"workspaces-cli/pkg/theme"
+	"workspaces-cli/pkg/workspaces"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func init() {
+	registerCommand(command{
+		name:        "doctor",
+		description: "check the database for orphaned and inconsistent records",
+		available:   hasDoctor,
+		handler: func(m *Application, ctx context.Context) tea.Cmd {
+			m.startMode(modes.DOCTOR)
+			return tea.Batch(m.doctorRenderer, m.diagnose(ctx))
+		},
+	})
+	registerAction(modes.DOCTOR, action{"close", "close the doctor", func(m *Application, ctx context.Context) tea.Cmd {
+		m.resetMode()
+		return m.defaultRenderer
+	}})
+	registerAction(modes.DOCTOR, action{"cursor_up", "move the cursor up", func(m *Application, ctx context.Context) tea.Cmd {
+		m.doctorCursor = max(m.doctorCursor-1, 0)
+		m.doctorPurge, m.doctorRelink = "", ""
+		return m.doctorRenderer
+	}})
+	registerAction(modes.DOCTOR, action{"cursor_down", "move the cursor down", func(m *Application, ctx context.Context) tea.Cmd {
+		m.doctorCursor = max(min(m.doctorCursor+1, len(m.problems)-1), 0)
+		m.doctorPurge, m.doctorRelink = "", ""
+		return m.doctorRenderer
+	}})
+	registerAction(modes.DOCTOR, action{"fix", "apply the fix of the selected problem, a relink by name asks twice", func(m *Application, ctx context.Context) tea.Cmd {
+		if m.problems == nil || m.doctorCursor >= len(m.problems) {
+			return nil
+		}
+		p := m.problems[m.doctorCursor]
+		if p.ByName && m.doctorRelink != p.WorkspaceId {
+			m.doctorRelink = p.WorkspaceId
+			return m.doctorRenderer
+		}
+		m.doctorRelink = ""
+		return m.repair(ctx, []db.Problem{p})
+	}})
+	registerAction(modes.DOCTOR, action{"fix_all", "apply every automatic fix, nothing is purged or relinked by name", func(m *Application, ctx context.Context) tea.Cmd {
+		// a relink by name may point the history at an unrelated workspace, it is only
+		// applied when fixed on its own
+		return m.repair(ctx, slices.DeleteFunc(append([]db.Problem{}, m.problems...), func(p db.Problem) bool { return p.ByName }))
+	}})
+	registerAction(modes.DOCTOR, action{"purge", "purge the history of the selected missing workspace, asks twice", func(m *Application, ctx context.Context) tea.Cmd {
+		if m.problems == nil || m.doctorCursor >= len(m.problems) {
+			return nil
+		}
+		p := m.problems[m.doctorCursor]
+		if !p.Purgeable {
+			return m.showStatus(theme.WARNING, "only the history of missing workspaces can be purged")
+		}
+		if m.doctorPurge != p.WorkspaceId {
+			m.doctorPurge = p.WorkspaceId
+			return m.doctorRenderer
+		}
+		m.doctorPurge = ""
+		return m.repair(ctx, []db.Problem{p.Purge()})
+	}})
+}
+
+// hasDoctor: only some stores can check themselves
+func hasDoctor(m *Application) bool {
+	_, ok := m.store.(db.Doctor)
+	return ok
+}
+
+// diagnose: runs the checks in the background, they stat every recorded path
+func (m *Application) diagnose(ctx context.Context) tea.Cmd {
+	m.problems = nil
+	ws := append([]workspaces.Workspace{}, m.workspaces...)
+	return func() tea.Msg {
+		problems, err := m.store.(db.Doctor).Diagnose(ctx, ws)
+		return doctorcmd{problems: problems, err: err}
+	}
+}
+
+// repair: backs the database up, applies the fixes, then checks again. nothing is
+// changed when the backup fails.
+func (m *Application) repair(ctx context.Context, problems []db.Problem) tea.Cmd {
+	problems = slices.DeleteFunc(append([]db.Problem{}, problems...), func(p db.Problem) bool { return p.Fix == db.FIX_NONE })
+	clear(m.details)
+	m.cancelDetailLoad()
+	m.problems = nil
+	ws := append([]workspaces.Workspace{}, m.workspaces...)
+	return func() tea.Msg {
+		d := m.store.(db.Doctor)
+		fixed := 0
+		errs := []string{}
+		if len(problems) > 0 {
+			if _, err := d.BackupBeforeRepair(ctx); err != nil {
+				errs = append(errs, fmt.Sprintf("backup before repair, nothing was changed: %s", err))
+				problems = nil
+			}
+		}
+		for _, p := range problems {
+			if err := d.Repair(ctx, p); err != nil {
+				errs = append(errs, fmt.Sprintf("%s: %s", p, err))
+				continue
+			}
+			fixed++
+		}
+		problems, err := d.Diagnose(ctx, ws)
+		return doctorcmd{problems: problems, err: err, fixed: fixed, errs: errs}
+	}
+}
+
+func (m *Application) generateDoctorString() string {
+	b := strings.Builder{}
+	b.WriteString(theme.Render(theme.PROMPT, "DOCTOR") + "\n")
+	switch {
+	case m.problems == nil:
+		b.WriteString(theme.Render(theme.FOOTER, "   checking...") + "\n")
+		return b.String()
+	case len(m.problems) == 0:
+		b.WriteString(theme.Render(theme.SUCCESS, "   no problems found") + "\n")
+		return b.String()
+	}
+	for i := range m.problems {
+		p := &m.problems[i]
+		cursor := "   "
+		if i == m.doctorCursor {
+			cursor = " > "
+		}
+		b.WriteString(cursor + theme.Render(theme.WARNING, p.String()) + "\n")
+		b.WriteString("     " + theme.Render(theme.FOOTER, p.FixDescription()) + "\n")
+		if p.Purgeable && p.WorkspaceId == m.doctorPurge {
+			b.WriteString("     " + theme.Render(theme.ERROR, "purge again to delete its checkpoints, tags and activity") + "\n")
+		}
+		if p.ByName && p.WorkspaceId == m.doctorRelink {
+			b.WriteString("     " + theme.Render(theme.ERROR, "fix again to give its history to "+p.Target.DirEntry.Name()) + "\n")
+		}
+	}
+	return b.String()
+}
+
+func (m *Application) doctorRenderer() tea.Msg {
+	return renderpanescmd{main: m.generateDoctorString(), footer: m.generateFooter()}
+}
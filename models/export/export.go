@@ -0,0 +1,176 @@
+package export
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"workspaces-cli/models/db"
+	"workspaces-cli/pkg/gitinfo"
+)
+
+const (
+	FORMAT_MARKDOWN string = "md"
+	FORMAT_JSON     string = "json"
+	FORMAT_CSV      string = "csv"
+
+	JSON_VERSION int = 1
+)
+
+// Workspace: a recorded workspace with its checkpoints, oldest first
+type Workspace struct {
+	Id          string       `json:"id"`
+	Name        string       `json:"name"`
+	Path        string       `json:"path"`
+	Tags        []string     `json:"tags"`
+	Checkpoints []Checkpoint `json:"checkpoints"`
+}
+
+type Checkpoint struct {
+	Id    string         `json:"id"`
+	Date  time.Time      `json:"date"`
+	Value string         `json:"value"`
+	Git   *gitinfo.State `json:"git,omitempty"` // nil outside of a repository
+}
+
+func newCheckpoint(c db.Checkpoint) Checkpoint {
+	cp := Checkpoint{Id: c.Id, Date: c.Date, Value: c.Value}
+	if !c.Git.IsZero() {
+		git := c.Git
+		cp.Git = &git
+	}
+	return cp
+}
+
+// state: the git state of the checkpoint, the zero state without one
+func (c Checkpoint) state() gitinfo.State {
+	if c.Git == nil {
+		return gitinfo.State{}
+	}
+	return *c.Git
+}
+
+// Document: the json export
+type Document struct {
+	Version    int         `json:"version"`
+	Exported   time.Time   `json:"exported"`
+	Workspaces []Workspace `json:"workspaces"`
+}
+
+// Filter: what to export, zero values don't filter
+type Filter struct {
+	Workspaces []string // names
+	Tag        string
+	Since      time.Time // inclusive
+	Until      time.Time // exclusive
+}
+
+func (f Filter) hasDates() bool {
+	return !f.Since.IsZero() || !f.Until.IsZero()
+}
+
+func (f Filter) matches(r db.Record) bool {
+	if len(f.Workspaces) > 0 && !slices.Contains(f.Workspaces, r.Name) {
+		return false
+	}
+	return f.Tag == "" || slices.Contains(r.Tags, f.Tag)
+}
+
+func (f Filter) inRange(t time.Time) bool {
+	return (f.Since.IsZero() || !t.Before(f.Since)) && (f.Until.IsZero() || t.Before(f.Until))
+}
+
+// Collect: the workspaces and checkpoints the filter selects. with a date range,
+// workspaces without a checkpoint in it are left out.
+func Collect(ctx context.Context, store db.Store, f Filter) ([]Workspace, error) {
+	records, err := store.Workspaces(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("workspaces: %w", err)
+	}
+	ws := []Workspace{}
+	for _, r := range records {
+		if !f.matches(r) {
+			continue
+		}
+		checkpoints, err := store.Checkpoints(ctx, r.Id)
+		if err != nil {
+			return nil, fmt.Errorf("checkpoints of %s: %w", r.Name, err)
+		}
+		w := Workspace{Id: r.Id, Name: r.Name, Path: r.Path, Tags: r.Tags, Checkpoints: []Checkpoint{}}
+		if w.Tags == nil {
+			w.Tags = []string{}
+		}
+		for _, c := range checkpoints {
+			if f.inRange(c.Date) {
+				w.Checkpoints = append(w.Checkpoints, newCheckpoint(c))
+			}
+		}
+		if f.hasDates() && len(w.Checkpoints) == 0 {
+			continue
+		}
+		ws = append(ws, w)
+	}
+	return ws, nil
+}
+
+func WriteJSON(out io.Writer, ws []Workspace) error {
+	e := json.NewEncoder(out)
+	e.SetIndent("", "  ")
+	return e.Encode(Document{Version: JSON_VERSION, Exported: time.Now().UTC(), Workspaces: ws})
+}
+
+// WriteCSV: one row per checkpoint, tags joined with ;
+func WriteCSV(out io.Writer, ws []Workspace) error {
+	c := csv.NewWriter(out)
+	c.Write([]string{"workspace_id", "workspace", "path", "tags", "checkpoint_id", "date", "value", "branch", "head", "dirty", "diffstat"})
+	for _, w := range ws {
+		for _, cp := range w.Checkpoints {
+			git := cp.state()
+			c.Write([]string{w.Id, w.Name, w.Path, strings.Join(w.Tags, ";"), cp.Id, cp.Date.UTC().Format(time.RFC3339), cp.Value,
+				git.Branch, git.Head, strconv.Itoa(git.Dirty), git.DiffStat})
+		}
+	}
+	c.Flush()
+	return c.Error()
+}
+
+// WriteMarkdown: the workspace as a document, a dated heading per checkpoint. the ids
+// and git states go in comments so that the file can be imported back.
+func WriteMarkdown(out io.Writer, w Workspace) error {
+	b := strings.Builder{}
+	b.WriteString(fmt.Sprintf("# %s\n\n", w.Name))
+	b.WriteString(fmt.Sprintf("<!-- workspace: %s -->\n", w.Id))
+	b.WriteString(fmt.Sprintf("- path: `%s`\n", w.Path))
+	if len(w.Tags) > 0 {
+		b.WriteString(fmt.Sprintf("- tags: %s\n", strings.Join(w.Tags, ", ")))
+	}
+	for _, c := range w.Checkpoints {
+		b.WriteString(fmt.Sprintf("\n## %s\n", c.Date.Local().Format("2006-01-02 15:04")))
+		b.WriteString(fmt.Sprintf("<!-- checkpoint: %s %s -->\n", c.Id, c.Date.UTC().Format(time.RFC3339)))
+		if c.Git != nil {
+			git, err := json.Marshal(c.Git)
+			if err != nil {
+				return err
+			}
+			b.WriteString(fmt.Sprintf("<!-- git: %s -->\n\n", git))
+			b.WriteString(fmt.Sprintf("*%s*\n", c.Git))
+		}
+		b.WriteString("\n")
+		b.WriteString(c.Value + "\n")
+	}
+	_, err := io.WriteString(out, b.String())
+	return err
+}
+
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// MarkdownFile: the file name of the workspace document
+func MarkdownFile(w Workspace) string {
+	return unsafeFileChars.ReplaceAllString(w.Name, "_") + ".md"
+}
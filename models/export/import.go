@@ -0,0 +1,304 @@
+package export
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+	"workspaces-cli/models/db"
+	"workspaces-cli/pkg/gitinfo"
+	"workspaces-cli/pkg/workspaces"
+
+	"github.com/google/uuid"
+)
+
+const (
+	MATCH_ID   string = "id"
+	MATCH_PATH string = "path"
+	MATCH_NAME string = "name"
+)
+
+// headingDates: what a dated markdown heading may look like, in local time
+var headingDates = []string{"2006-01-02 15:04", "2006-01-02 15:04:05", time.DateOnly}
+
+// ReadJSON: workspaces of a json export
+func ReadJSON(in io.Reader) ([]Workspace, error) {
+	var d Document
+	if err := json.NewDecoder(in).Decode(&d); err != nil {
+		return nil, err
+	}
+	if d.Version != JSON_VERSION {
+		return nil, fmt.Errorf("unsupported version %d", d.Version)
+	}
+	for i := range d.Workspaces {
+		normalizeIds(&d.Workspaces[i])
+	}
+	return d.Workspaces, nil
+}
+
+// cutComment: the content of an html comment starting with key, like <!-- key: value -->
+func cutComment(line string, key string) (string, bool) {
+	rest, ok := strings.CutPrefix(strings.TrimSpace(line), "<!-- "+key+":")
+	if !ok {
+		return "", false
+	}
+	rest, ok = strings.CutSuffix(rest, "-->")
+	return strings.TrimSpace(rest), ok
+}
+
+func parseHeadingDate(s string) (time.Time, error) {
+	for _, layout := range headingDates {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("'%s' is not a date", s)
+}
+
+// isMarked: whether the document is one of ours, its checkpoints start at a heading
+// followed by a checkpoint comment
+func isMarked(lines []string) bool {
+	return slices.ContainsFunc(lines, func(l string) bool {
+		_, ok := cutComment(l, "checkpoint")
+		return ok
+	})
+}
+
+// ReadMarkdown: a workspace document, ours or written by hand. the title names the
+// workspace, name is used without one. in our documents a checkpoint starts at a level 2
+// heading followed by its checkpoint comment, in others at each level 2 heading that is a
+// date. other headings are part of the note.
+func ReadMarkdown(in io.Reader, name string) (Workspace, error) {
+	w := Workspace{Name: name, Tags: []string{}, Checkpoints: []Checkpoint{}}
+	lines := []string{}
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return w, err
+	}
+	marked := isMarked(lines)
+	var (
+		current *Checkpoint
+		body    []string
+	)
+	flush := func() {
+		if current != nil {
+			current.Value = strings.TrimSpace(strings.Join(body, "\n"))
+			w.Checkpoints = append(w.Checkpoints, *current)
+		}
+		current, body = nil, nil
+	}
+	for i, line := range lines {
+		n := i + 1
+		if heading, ok := strings.CutPrefix(line, "## "); ok {
+			date, err := parseHeadingDate(strings.TrimSpace(heading))
+			starts := err == nil
+			if marked {
+				_, starts = cutComment(lineAt(lines, i+1), "checkpoint")
+			}
+			if starts {
+				if err != nil {
+					return w, fmt.Errorf("line %d: %w", n, err)
+				}
+				flush()
+				current = &Checkpoint{Date: date}
+				continue
+			}
+			if current == nil {
+				return w, fmt.Errorf("line %d: '%s' doesn't start a checkpoint", n, strings.TrimSpace(heading))
+			}
+		}
+		switch {
+		case current == nil && strings.HasPrefix(line, "# "):
+			w.Name = strings.TrimSpace(line[2:])
+		case current == nil && strings.HasPrefix(line, "- path:"):
+			w.Path = strings.Trim(strings.TrimSpace(strings.TrimPrefix(line, "- path:")), "`")
+		case current == nil && strings.HasPrefix(line, "- tags:"):
+			for _, t := range strings.Split(strings.TrimPrefix(line, "- tags:"), ",") {
+				if t = strings.TrimSpace(t); t != "" {
+					w.Tags = append(w.Tags, t)
+				}
+			}
+		default:
+			if id, ok := cutComment(line, "workspace"); ok && current == nil {
+				w.Id = id
+				continue
+			}
+			// the exact date and id, the heading only has the minute
+			if meta, ok := cutComment(line, "checkpoint"); ok && current != nil && len(body) == 0 {
+				for _, f := range strings.Fields(meta) {
+					if t, err := time.Parse(time.RFC3339, f); err == nil {
+						current.Date = t
+					} else {
+						current.Id = f
+					}
+				}
+				continue
+			}
+			if git, ok := cutComment(line, "git"); ok && current != nil && len(body) == 0 {
+				current.Git = &gitinfo.State{}
+				if err := json.Unmarshal([]byte(git), current.Git); err != nil {
+					return w, fmt.Errorf("line %d: git: %w", n, err)
+				}
+				continue
+			}
+			// the readable git state written under the comment isn't part of the note
+			if current != nil && current.Git != nil && strings.TrimSpace(strings.Join(body, "")) == "" &&
+				strings.TrimSpace(line) == fmt.Sprintf("*%s*", current.Git) {
+				continue
+			}
+			if current != nil {
+				body = append(body, line)
+			}
+		}
+	}
+	flush()
+	normalizeIds(&w)
+	return w, nil
+}
+
+// lineAt: the line at i, empty past the end
+func lineAt(lines []string, i int) string {
+	if i < len(lines) {
+		return lines[i]
+	}
+	return ""
+}
+
+// normalizeId: the id in its canonical form, empty when it isn't one. the store makes a
+// new one for checkpoints without an id.
+func normalizeId(id string) string {
+	u, err := uuid.Parse(strings.TrimSpace(id))
+	if err != nil {
+		return ""
+	}
+	return u.String()
+}
+
+func normalizeIds(w *Workspace) {
+	w.Id = normalizeId(w.Id)
+	for i := range w.Checkpoints {
+		w.Checkpoints[i].Id = normalizeId(w.Checkpoints[i].Id)
+	}
+}
+
+// Change: what importing a workspace does
+type Change struct {
+	Source     Workspace
+	Target     *workspaces.Workspace // nil when no workspace matched
+	MatchedBy  string
+	New        []Checkpoint
+	NewTags    []string
+	Duplicates int
+}
+
+// match: the local workspace the imported one belongs to, by id file, path and then name
+func match(local []workspaces.Workspace, w Workspace) (*workspaces.Workspace, string) {
+	if w.Id != "" {
+		for i := range local {
+			if id, _ := local[i].ID(); id == w.Id {
+				return &local[i], MATCH_ID
+			}
+		}
+	}
+	if w.Path != "" {
+		for i := range local {
+			if local[i].Path() == w.Path {
+				return &local[i], MATCH_PATH
+			}
+		}
+	}
+	for i := range local {
+		if local[i].DirEntry.Name() == w.Name {
+			return &local[i], MATCH_NAME
+		}
+	}
+	return nil, ""
+}
+
+// contentHash: checkpoints without a known id are the same when their text is
+func contentHash(value string) string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(strings.TrimSpace(value))))
+}
+
+// Plan: the changes importing ws would make. checkpoints already in the store, or
+// earlier in the import, are duplicates when their id or their content matches.
+func Plan(ctx context.Context, store db.Store, local []workspaces.Workspace, ws []Workspace) ([]Change, error) {
+	type seen struct{ ids, hashes, tags map[string]bool }
+	known := map[string]*seen{}
+	changes := make([]Change, 0, len(ws))
+	for _, w := range ws {
+		c := Change{Source: w}
+		c.Target, c.MatchedBy = match(local, w)
+		if c.Target == nil {
+			changes = append(changes, c)
+			continue
+		}
+		s, ok := known[c.Target.Path()]
+		if !ok {
+			s = &seen{ids: map[string]bool{}, hashes: map[string]bool{}, tags: map[string]bool{}}
+			existing, err := store.GetCheckpoints(ctx, *c.Target, db.NO_LIMIT)
+			if err != nil {
+				return nil, fmt.Errorf("checkpoints of %s: %w", c.Target.DirEntry.Name(), err)
+			}
+			for _, e := range existing {
+				s.ids[e.Id], s.hashes[contentHash(e.Value)] = true, true
+			}
+			tags, err := store.GetTags(ctx, *c.Target)
+			if err != nil {
+				return nil, fmt.Errorf("tags of %s: %w", c.Target.DirEntry.Name(), err)
+			}
+			for _, t := range tags {
+				s.tags[t] = true
+			}
+			known[c.Target.Path()] = s
+		}
+		for _, cp := range w.Checkpoints {
+			if strings.TrimSpace(cp.Value) == "" {
+				continue
+			}
+			h := contentHash(cp.Value)
+			if (cp.Id != "" && s.ids[cp.Id]) || s.hashes[h] {
+				c.Duplicates++
+				continue
+			}
+			s.ids[cp.Id], s.hashes[h] = true, true
+			c.New = append(c.New, cp)
+		}
+		for _, t := range w.Tags {
+			if !s.tags[t] {
+				s.tags[t] = true
+				c.NewTags = append(c.NewTags, t)
+			}
+		}
+		changes = append(changes, c)
+	}
+	return changes, nil
+}
+
+// Apply: stores the new checkpoints and tags of the changes
+func Apply(ctx context.Context, store db.Store, changes []Change) error {
+	for _, c := range changes {
+		if c.Target == nil {
+			continue
+		}
+		for _, cp := range c.New {
+			if err := store.ImportCheckpoint(ctx, *c.Target, db.Checkpoint{Id: cp.Id, Value: cp.Value, Date: cp.Date, Git: cp.state()}); err != nil {
+				return fmt.Errorf("%s: %w", c.Target.DirEntry.Name(), err)
+			}
+		}
+		for _, t := range c.NewTags {
+			if err := store.AddTag(ctx, *c.Target, t); err != nil {
+				return fmt.Errorf("%s: %w", c.Target.DirEntry.Name(), err)
+			}
+		}
+	}
+	return nil
+}
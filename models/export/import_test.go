@@ -0,0 +1,163 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+	"workspaces-cli/models/db"
+	"workspaces-cli/pkg/gitinfo"
+	"workspaces-cli/pkg/workspaces"
+)
+
+// seeded: a store with checkpoints whose notes look like markdown, and the workspaces they belong to
+func seeded(t *testing.T) (db.Store, []workspaces.Workspace) {
+	t.Helper()
+	ctx := context.Background()
+	root := t.TempDir()
+	for _, n := range []string{"api", "web"} {
+		if err := os.Mkdir(path.Join(root, n), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	local, err := workspaces.Load(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := db.NewMemory()
+	t.Cleanup(func() { store.Close() })
+	notes := []string{
+		"plain note",
+		"## 2024-01-02 10:00\nnot a checkpoint, a heading of the note",
+		"## todo\n- [ ] tests\n\n## done\n- [x] parser",
+	}
+	git := gitinfo.State{Branch: "main", Head: "1a2b3c4", Dirty: 1, DiffStat: "1 file changed, 2 insertions(+)"}
+	for i := range local {
+		for _, n := range notes {
+			if err := store.InsertCheckpoint(ctx, local[i], []byte(n), git); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := store.AddTag(ctx, local[i], "team"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := store.InsertCheckpoint(ctx, local[0], []byte("outside of a repository"), gitinfo.State{}); err != nil {
+		t.Fatal(err)
+	}
+	return store, local
+}
+
+// expectRoundTrip: the imported workspaces are the exported ones, and importing them changes nothing
+func expectRoundTrip(t *testing.T, store db.Store, local []workspaces.Workspace, exported, imported []Workspace) {
+	t.Helper()
+	if len(imported) != len(exported) {
+		t.Fatalf("%d workspaces, expected %d", len(imported), len(exported))
+	}
+	for i, w := range imported {
+		e := exported[i]
+		if w.Id != e.Id || w.Name != e.Name || w.Path != e.Path || strings.Join(w.Tags, ",") != strings.Join(e.Tags, ",") {
+			t.Errorf("workspace %+v, expected %+v", w, e)
+		}
+		if len(w.Checkpoints) != len(e.Checkpoints) {
+			t.Fatalf("%s: %d checkpoints, expected %d", w.Name, len(w.Checkpoints), len(e.Checkpoints))
+		}
+		for j, c := range w.Checkpoints {
+			ec := e.Checkpoints[j]
+			// markdown keeps the date to the second
+			sameDate := c.Date.Truncate(time.Second).Equal(ec.Date.Truncate(time.Second))
+			if c.Id != ec.Id || c.Value != ec.Value || !sameDate || c.state() != ec.state() {
+				t.Errorf("%s: checkpoint %+v, expected %+v", w.Name, c, ec)
+			}
+		}
+	}
+	changes, err := Plan(context.Background(), store, local, imported)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range changes {
+		if c.Target == nil || len(c.New) > 0 || len(c.NewTags) > 0 || c.Duplicates != len(c.Source.Checkpoints) {
+			t.Errorf("%s: %d new, %v new tags, %d duplicates", c.Source.Name, len(c.New), c.NewTags, c.Duplicates)
+		}
+	}
+}
+
+func TestRoundTripJSON(t *testing.T) {
+	store, local := seeded(t)
+	exported, err := Collect(context.Background(), store, Filter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := bytes.Buffer{}
+	if err := WriteJSON(&b, exported); err != nil {
+		t.Fatal(err)
+	}
+	imported, err := ReadJSON(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectRoundTrip(t, store, local, exported, imported)
+}
+
+func TestRoundTripMarkdown(t *testing.T) {
+	store, local := seeded(t)
+	exported, err := Collect(context.Background(), store, Filter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	imported := []Workspace{}
+	for _, e := range exported {
+		b := bytes.Buffer{}
+		if err := WriteMarkdown(&b, e); err != nil {
+			t.Fatal(err)
+		}
+		w, err := ReadMarkdown(&b, "unused")
+		if err != nil {
+			t.Fatal(err)
+		}
+		imported = append(imported, w)
+	}
+	expectRoundTrip(t, store, local, exported, imported)
+}
+
+func TestReadMarkdownByHand(t *testing.T) {
+	doc := "# api\n\n## 2024-01-02 10:00\nfirst\n\n## notes\nstill the first\n\n## 2024-01-03\nsecond\n"
+	w, err := ReadMarkdown(strings.NewReader(doc), "unused")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w.Name != "api" || len(w.Checkpoints) != 2 {
+		t.Fatalf("%+v", w)
+	}
+	if v := w.Checkpoints[0].Value; v != "first\n\n## notes\nstill the first" {
+		t.Errorf("first: %q", v)
+	}
+	if _, err := ReadMarkdown(strings.NewReader("## notes\n"), "api"); err == nil {
+		t.Error("a heading before any checkpoint should fail")
+	}
+}
+
+func TestReadIds(t *testing.T) {
+	doc := `{"version": 1, "workspaces": [{"id": "../../etc", "name": "api", "checkpoints": [
+		{"id": "a", "value": "short"},
+		{"id": " {4B8C0F4E-2F1D-4C57-9A43-6F0F7E1D2C3B} ", "value": "braces"}]}]}`
+	ws, err := ReadJSON(strings.NewReader(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := ws[0]
+	if w.Id != "" || w.Checkpoints[0].Id != "" || w.Checkpoints[1].Id != "4b8c0f4e-2f1d-4c57-9a43-6f0f7e1d2c3b" {
+		t.Errorf("ids: %s %s %s", w.Id, w.Checkpoints[0].Id, w.Checkpoints[1].Id)
+	}
+	md := "# api\n<!-- workspace: x -->\n\n## 2024-01-02 10:00\n<!-- checkpoint: ../x 2024-01-02T09:00:00Z -->\n\nnote\n"
+	m, err := ReadMarkdown(strings.NewReader(md), "api")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Id != "" || m.Checkpoints[0].Id != "" || !m.Checkpoints[0].Date.Equal(time.Date(2024, 1, 2, 9, 0, 0, 0, time.UTC)) {
+		t.Errorf("%+v", m)
+	}
+}
@@ -0,0 +1,325 @@
+package gitsync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+	"workspaces-cli/models/db"
+	"workspaces-cli/pkg/gitinfo"
+	"workspaces-cli/pkg/workspaces"
+)
+
+const (
+	CHECKPOINTS_DIR string = "checkpoints"
+	BRANCH          string = "main" // fixed, machines may default to different branch names
+	PUSH_ATTEMPTS   int    = 3      // another machine may push between our pull and push
+)
+
+// Entry: a checkpoint as it is kept in the repository, one file each. files are only
+// ever added, so merges between machines never conflict.
+type Entry struct {
+	Id        string
+	Date      time.Time
+	Workspace string // directory name
+	Remote    string // normalized remote url, empty for workspaces without one
+	Git       gitinfo.State
+	Value     string
+}
+
+// Result: what a sync did
+type Result struct {
+	Pulled    int // checkpoints added to the store
+	Pushed    int // checkpoints added to the repository
+	Unmatched int // entries of workspaces this machine doesn't have
+}
+
+// local: a workspace on this machine and the key other machines know it by
+type local struct {
+	w      workspaces.Workspace
+	remote string
+}
+
+var unsafeKeyChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// key: the directory of the workspace in the repository, the same on every machine
+func (e Entry) key() string {
+	if e.Remote != "" {
+		return unsafeKeyChars.ReplaceAllString(e.Remote, "_")
+	}
+	return unsafeKeyChars.ReplaceAllString(e.Workspace, "_")
+}
+
+func (e Entry) file() string {
+	return path.Join(CHECKPOINTS_DIR, e.key(), e.Date.UTC().Format("20060102T150405Z")+"-"+e.Id+".md")
+}
+
+func (e Entry) marshal() []byte {
+	return []byte(fmt.Sprintf("---\nid: %s\ndate: %s\nworkspace: %s\nremote: %s\n%s---\n\n%s\n",
+		e.Id, e.Date.UTC().Format(time.RFC3339), e.Workspace, e.Remote, e.Git.FrontMatter(), e.Value))
+}
+
+func parseEntry(data []byte) (Entry, error) {
+	var e Entry
+	rest, ok := strings.CutPrefix(string(data), "---\n")
+	if !ok {
+		return e, errors.New("missing front matter")
+	}
+	front, body, ok := strings.Cut(rest, "---\n")
+	if !ok {
+		return e, errors.New("unterminated front matter")
+	}
+	for _, line := range strings.Split(front, "\n") {
+		k, v, _ := strings.Cut(line, ":")
+		v = strings.TrimSpace(v)
+		if e.Git.ParseFrontMatter(strings.TrimSpace(k), v) {
+			continue
+		}
+		switch strings.TrimSpace(k) {
+		case "id":
+			e.Id = v
+		case "workspace":
+			e.Workspace = v
+		case "remote":
+			e.Remote = v
+		case "date":
+			date, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return e, fmt.Errorf("date: %w", err)
+			}
+			e.Date = date
+		}
+	}
+	if e.Id == "" {
+		return e, errors.New("missing id")
+	}
+	e.Value = strings.TrimSpace(body)
+	return e, nil
+}
+
+// readEntries: every checkpoint in the repository
+func readEntries(dir string) ([]Entry, error) {
+	entries := []Entry{}
+	root := path.Join(dir, CHECKPOINTS_DIR)
+	err := fs.WalkDir(os.DirFS(root), ".", func(p string, d fs.DirEntry, err error) error {
+		if errors.Is(err, fs.ErrNotExist) && p == "." {
+			return fs.SkipAll
+		} else if err != nil {
+			return err
+		}
+		if d.IsDir() || path.Ext(p) != ".md" {
+			return nil
+		}
+		data, err := os.ReadFile(path.Join(root, p))
+		if err != nil {
+			return err
+		}
+		e, err := parseEntry(data)
+		if err != nil {
+			return fmt.Errorf("%s: %w", p, err)
+		}
+		entries = append(entries, e)
+		return nil
+	})
+	return entries, err
+}
+
+// locals: the workspaces of this machine with their remotes, workspaces that aren't
+// repositories go by their name
+func locals(ws []workspaces.Workspace) []local {
+	ls := make([]local, 0, len(ws))
+	for _, w := range ws {
+		l := local{w: w}
+		if gitinfo.IsRepository(w.Path()) {
+			if url, err := gitinfo.RemoteURL(w.Path()); err == nil {
+				l.remote = gitinfo.NormalizeRemote(url)
+			}
+		}
+		ls = append(ls, l)
+	}
+	return ls
+}
+
+// match: the local workspace an entry belongs to, by remote when it has one, by name otherwise
+func match(ls []local, e Entry) *local {
+	for i := range ls {
+		if e.Remote != "" && ls[i].remote == e.Remote {
+			return &ls[i]
+		}
+	}
+	for i := range ls {
+		if ls[i].w.DirEntry.Name() == e.Workspace && (e.Remote == "" || ls[i].remote == "") {
+			return &ls[i]
+		}
+	}
+	return nil
+}
+
+// ensureRepository: a clone of the remote in dir, or a local repository without one
+func ensureRepository(ctx context.Context, dir string, remote string) error {
+	// not IsRepository, the directory may sit inside another repository
+	if _, err := os.Stat(path.Join(dir, ".git")); err == nil {
+		return nil
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	if remote == "" {
+		_, err := gitinfo.Run(ctx, dir, "init", "-q", "-b", BRANCH)
+		return err
+	}
+	slog.DebugContext(ctx, "clone sync repository", "remote", remote, "dir", dir)
+	if _, err := gitinfo.Run(ctx, dir, "clone", "-q", remote, "."); err != nil {
+		return err
+	}
+	// the remote branch is pulled into it, even when the clone checked out nothing
+	_, err := gitinfo.Run(ctx, dir, "symbolic-ref", "HEAD", "refs/heads/"+BRANCH)
+	return err
+}
+
+// hasRemoteBranch: an empty bare repository has nothing to pull yet
+func hasRemoteBranch(ctx context.Context, dir string) (bool, error) {
+	out, err := gitinfo.Run(ctx, dir, "ls-remote", "--heads", "origin", BRANCH)
+	return out != "", err
+}
+
+func pull(ctx context.Context, dir string) error {
+	ok, err := hasRemoteBranch(ctx, dir)
+	if err != nil || !ok {
+		return err
+	}
+	args := append(identity(ctx, dir), "pull", "-q", "--no-rebase", "--no-edit", "origin", BRANCH)
+	_, err = gitinfo.Run(ctx, dir, args...)
+	return err
+}
+
+// identity: a fallback author for machines without one, pulls may have to merge
+func identity(ctx context.Context, dir string) []string {
+	if email, _ := gitinfo.Run(ctx, dir, "config", "user.email"); email != "" {
+		return nil
+	}
+	host, _ := os.Hostname()
+	return []string{"-c", "user.name=workspaces-cli", "-c", "user.email=workspaces-cli@" + host}
+}
+
+// commit: commits whatever was added
+func commit(ctx context.Context, dir string, message string) error {
+	if _, err := gitinfo.Run(ctx, dir, "add", "-A"); err != nil {
+		return err
+	}
+	if status, err := gitinfo.Run(ctx, dir, "status", "--porcelain"); err != nil || status == "" {
+		return err
+	}
+	_, err := gitinfo.Run(ctx, dir, append(identity(ctx, dir), "commit", "-q", "-m", message)...)
+	return err
+}
+
+// storedIds: the ids of every checkpoint in the store, also of workspaces this machine
+// no longer lists. an entry already stored under another workspace isn't imported again.
+func storedIds(ctx context.Context, store db.Store) (map[string]bool, error) {
+	records, err := store.Workspaces(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("workspaces: %w", err)
+	}
+	ids := map[string]bool{}
+	for _, rec := range records {
+		checkpoints, err := store.Checkpoints(ctx, rec.Id)
+		if err != nil {
+			return nil, fmt.Errorf("checkpoints of %s: %w", rec.Name, err)
+		}
+		for _, c := range checkpoints {
+			ids[c.Id] = true
+		}
+	}
+	return ids, nil
+}
+
+// Sync: pulls the repository, adds the checkpoints other machines pushed to the store,
+// writes the ones only the store has and pushes them. checkpoints are merged by id.
+func Sync(ctx context.Context, store db.Store, ws []workspaces.Workspace, dir string, remote string) (Result, error) {
+	var r Result
+	if err := ensureRepository(ctx, dir, remote); err != nil {
+		return r, fmt.Errorf("repository: %w", err)
+	}
+	if remote != "" {
+		if err := pull(ctx, dir); err != nil {
+			return r, fmt.Errorf("pull: %w", err)
+		}
+	}
+	incoming, err := readEntries(dir)
+	if err != nil {
+		return r, fmt.Errorf("read repository: %w", err)
+	}
+	inRepository := map[string]bool{}
+	for _, e := range incoming {
+		inRepository[e.Id] = true
+	}
+	inStore, err := storedIds(ctx, store)
+	if err != nil {
+		return r, err
+	}
+	ls := locals(ws)
+	for _, l := range ls {
+		checkpoints, err := store.GetCheckpoints(ctx, l.w, db.NO_LIMIT)
+		if err != nil {
+			return r, fmt.Errorf("checkpoints of %s: %w", l.w.DirEntry.Name(), err)
+		}
+		for _, c := range checkpoints {
+			if inRepository[c.Id] {
+				continue
+			}
+			e := Entry{Id: c.Id, Date: c.Date, Workspace: l.w.DirEntry.Name(), Remote: l.remote, Git: c.Git, Value: c.Value}
+			file := path.Join(dir, e.file())
+			if err := os.MkdirAll(path.Dir(file), 0o755); err != nil {
+				return r, err
+			}
+			if err := os.WriteFile(file, e.marshal(), 0o644); err != nil {
+				return r, err
+			}
+			inRepository[c.Id] = true
+			r.Pushed++
+		}
+	}
+	for _, e := range incoming {
+		if inStore[e.Id] {
+			continue
+		}
+		l := match(ls, e)
+		if l == nil {
+			r.Unmatched++
+			continue
+		}
+		if err := store.ImportCheckpoint(ctx, l.w, db.Checkpoint{Id: e.Id, Date: e.Date, Git: e.Git, Value: e.Value}); err != nil {
+			return r, fmt.Errorf("import into %s: %w", l.w.DirEntry.Name(), err)
+		}
+		inStore[e.Id] = true
+		r.Pulled++
+	}
+	host, _ := os.Hostname()
+	if err := commit(ctx, dir, fmt.Sprintf("%d checkpoint(s) from %s", r.Pushed, host)); err != nil {
+		return r, fmt.Errorf("commit: %w", err)
+	}
+	// also pushes what an earlier sync committed but couldn't push
+	if _, err := gitinfo.Run(ctx, dir, "rev-parse", "-q", "--verify", "HEAD"); remote == "" || err != nil {
+		return r, nil
+	}
+	for attempt := 1; ; attempt++ {
+		_, err := gitinfo.Run(ctx, dir, "push", "-q", "origin", "HEAD:"+BRANCH)
+		if err == nil || attempt == PUSH_ATTEMPTS {
+			if err != nil {
+				return r, fmt.Errorf("push: %w", err)
+			}
+			return r, nil
+		}
+		slog.DebugContext(ctx, "push rejected, pulling again", "attempt", attempt, "error", err)
+		if err := pull(ctx, dir); err != nil {
+			return r, fmt.Errorf("pull: %w", err)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package models
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"workspaces-cli/models/modes"
+	"workspaces-cli/pkg/theme"
+)
+
+var (
+	// footerHints: actions hinted in the footer of each mode, skipped when unbound
+	footerHints map[modes.InputMode][]string = map[modes.InputMode][]string{
+		modes.DEFAULT:        {"copy_menu", "open_workspace", "toggle_details", "command_palette", "help"},
+		modes.FILTER:         {"accept", "cancel"},
+		modes.SELECT_COMMAND: {"execute", "cancel"},
+		modes.HELP:           {"close"},
+		modes.MESSAGE_LOG:    {"close"},
+		modes.COPY:           {"execute", "cancel"},
+		modes.PROMPT:         {"accept", "cancel"},
+		modes.DOCTOR:         {"fix", "fix_all", "purge", "close"},
+	}
+	helpModes []modes.InputMode = []modes.InputMode{modes.DEFAULT, modes.FILTER, modes.SELECT_COMMAND, modes.HELP, modes.MESSAGE_LOG, modes.COPY, modes.PROMPT, modes.DOCTOR}
+)
+
+// actionDescription: description of the action or command bound in mode
+func (m *Application) actionDescription(mode modes.InputMode, name string) string {
+	if i := slices.IndexFunc(actionRegistry[mode], func(a action) bool { return a.name == name }); i >= 0 {
+		return actionRegistry[mode][i].description
+	}
+	if mode != modes.DEFAULT {
+		return ""
+	}
+	if i := slices.IndexFunc(commandRegistry, func(c command) bool { return c.name == name }); i >= 0 {
+		return commandRegistry[i].description
+	}
+	for i := range m.config.Commands {
+		if m.config.Commands[i].Name == name {
+			return userCommand(m.config.Commands[i]).description
+		}
+	}
+	return ""
+}
+
+func (m *Application) keysFor(mode modes.InputMode, name string) string {
+	return strings.Join(m.keymap.KeysFor(modes.Name(mode), name), ", ")
+}
+
+// visibleFooterHints: hinted actions of the active mode that are bound to a key, in the
+// order they're rendered
+func (m *Application) visibleFooterHints() []string {
+	names := make([]string, 0, len(footerHints[m.mode]))
+	for _, name := range footerHints[m.mode] {
+		if len(m.keymap.KeysFor(modes.Name(m.mode), name)) > 0 {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// generateFooterHints: one line per hinted action of the active mode
+func (m *Application) generateFooterHints() string {
+	b := strings.Builder{}
+	for _, name := range m.visibleFooterHints() {
+		key := m.keymap.KeysFor(modes.Name(m.mode), name)[0]
+		b.WriteString(theme.Render(theme.FOOTER, fmt.Sprintf("   type '%s' to %s", key, m.actionDescription(m.mode, name))) + "\n")
+	}
+	return b.String()
+}
+
+func writeHelpSection(b *strings.Builder, title string, rows [][3]string) {
+	keypadding, namepadding := 0, 0
+	for i := range rows {
+		keypadding = max(keypadding, len(rows[i][0]))
+		namepadding = max(namepadding, len(rows[i][1]))
+	}
+	b.WriteString(theme.Render(theme.PROMPT, title) + "\n")
+	for i := range rows {
+		keys := rows[i][0]
+		if keys == "" {
+			keys = "-"
+		}
+		b.WriteString(fmt.Sprintf("   %s   %-*s   %s\n",
+			theme.Render(theme.KEY, fmt.Sprintf("%-*s", keypadding, keys)),
+			namepadding, rows[i][1],
+			theme.Render(theme.FOOTER, rows[i][2])))
+	}
+}
+
+// generateHelpString: every action of every mode and every command available from the
+// palette, with the keys bound to them in the active keymap
+func (m *Application) generateHelpString() string {
+	b := strings.Builder{}
+	for _, mode := range helpModes {
+		rows := make([][3]string, 0, len(actionRegistry[mode]))
+		for _, a := range actionRegistry[mode] {
+			rows = append(rows, [3]string{m.keysFor(mode, a.name), a.name, a.description})
+		}
+		writeHelpSection(&b, strings.ToUpper(modes.Name(mode)), rows)
+	}
+	rows := make([][3]string, 0, len(m.commands))
+	for _, c := range m.commands {
+		rows = append(rows, [3]string{m.keysFor(modes.DEFAULT, c.name), c.name, c.description})
+	}
+	writeHelpSection(&b, fmt.Sprintf("COMMANDS (%s)", m.keysFor(modes.DEFAULT, "command_palette")), rows)
+	return b.String()
+}
@@ -18,3 +18,23 @@ func isWorkspaceModTimeMatch(w *workspaces.Workspace, s string) bool {
 func isFuzzyWorkspaceMatch(w *workspaces.Workspace, s string) bool {
 	return isWorkspaceNameMatch(w, s) || isWorkspaceModTimeMatch(w, s)
 }
+
+// fuzzyScore: matches the characters of pattern in order within s, ignoring case. lower
+// scores are better: late starts and gaps between matched characters add to the score.
+func fuzzyScore(pattern, s string) (int, bool) {
+	pattern, s = strings.ToLower(pattern), strings.ToLower(s)
+	score, last := 0, -1
+	for _, r := range pattern {
+		i := strings.IndexRune(s[last+1:], r)
+		if i < 0 {
+			return 0, false
+		}
+		if last < 0 {
+			score += i
+		} else {
+			score += i * 2
+		}
+		last += i + 1
+	}
+	return score, true
+}
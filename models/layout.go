@@ -0,0 +1,217 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"workspaces-cli/models/modes"
+	"workspaces-cli/pkg/config"
+	"workspaces-cli/pkg/theme"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/ansi"
+)
+
+const (
+	// ROW_FORMAT: a workspace row, cursor, mark, index, name, date and path
+	ROW_FORMAT   string = "%s %s %s   %s   %s   %s"
+	CURSOR_WIDTH int    = 2 // the cursor emoji takes two cells
+	MARK_WIDTH   int    = 1
+	INDEX_WIDTH  int    = 3
+	DATE_WIDTH   int    = len(time.DateOnly)
+	// ROW_FIXED_WIDTH: width of a workspace row without name and path, the separators
+	// of ROW_FORMAT and the columns of fixed width
+	ROW_FIXED_WIDTH int = len(ROW_FORMAT) - 6*len("%s") + CURSOR_WIDTH + MARK_WIDTH + INDEX_WIDTH + DATE_WIDTH
+	MIN_NAME_WIDTH  int = 8
+	MIN_ROWS        int = 1
+	// DETAIL_HEIGHT: the title, readme, languages, size, branch, tags, last used, commits
+	// and checkpoints with their git state. the bottom pane takes at most DETAIL_SHARE
+	// of the room the list would have, less on short terminals.
+	DETAIL_HEIGHT int = 1 + DETAIL_README_LINES + 5 + DETAIL_COMMITS + 2*DETAIL_CHECKPOINTS
+	DETAIL_SHARE  int = 2 // one half
+	// DETAIL_SIDE_WIDTH: width of the side detail pane, which is only used while the
+	// list keeps MIN_LIST_WIDTH next to it
+	DETAIL_SIDE_WIDTH int = 50
+	MIN_LIST_WIDTH    int = 40
+	SIDE_SEPARATOR        = " │ "
+	TRUNCATE_MARKER       = "..."
+)
+
+func lineCount(s string) int {
+	return strings.Count(s, "\n")
+}
+
+// fitLines: pads or cuts s to exactly n newline terminated lines
+func fitLines(s string, n int) string {
+	lines := strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+	if s == "" {
+		lines = nil
+	}
+	for len(lines) < n {
+		lines = append(lines, "")
+	}
+	return strings.Join(lines[:n], "\n") + "\n"
+}
+
+// truncateLeft: keeps the end of s, which is the informative part of a path, within n
+// cells. a wide character cut in half is dropped whole.
+func truncateLeft(s string, n int) string {
+	width := ansi.StringWidth(s)
+	if width <= n {
+		return s
+	}
+	marker := TRUNCATE_MARKER
+	if n <= len(marker) {
+		marker = ""
+	}
+	cut := width - n + len(marker)
+	t := ansi.TruncateLeft(s, cut, marker)
+	// ansi.TruncateLeft keeps a wide character it cuts into, one more cell drops it
+	for ansi.StringWidth(t) > n {
+		cut++
+		t = ansi.TruncateLeft(s, cut, marker)
+	}
+	return t
+}
+
+// isDetailSide: the detail pane is shown right of the list
+func (m *Application) isDetailSide() bool {
+	return m.isDetailActive && m.config.DetailPane == config.DETAIL_SIDE &&
+		m.width >= MIN_LIST_WIDTH+len(SIDE_SEPARATOR)+DETAIL_SIDE_WIDTH
+}
+
+// isDetailBottom: the detail pane is shown below the list, also where a side pane doesn't fit
+func (m *Application) isDetailBottom() bool {
+	return m.isDetailActive && !m.isDetailSide()
+}
+
+// listWidth: room for the list, the terminal width less the side pane
+func (m *Application) listWidth() int {
+	if m.isDetailSide() {
+		return m.width - len(SIDE_SEPARATOR) - DETAIL_SIDE_WIDTH
+	}
+	return m.width
+}
+
+// joinSide: the list with the detail pane right of it, line by line
+func (m *Application) joinSide(list string, detail string) string {
+	width := m.listWidth()
+	lines := strings.Split(strings.TrimSuffix(list, "\n"), "\n")
+	details := strings.Split(fitLines(detail, len(lines)), "\n")
+	b := strings.Builder{}
+	for i, l := range lines {
+		l = ansi.Truncate(l, width, "")
+		b.WriteString(l + strings.Repeat(" ", max(width-ansi.StringWidth(l), 0)))
+		b.WriteString(theme.Render(theme.FOOTER, SIDE_SEPARATOR) + ansi.Truncate(details[i], DETAIL_SIDE_WIDTH, "") + "\n")
+	}
+	return b.String()
+}
+
+// namePadding: width of the name column. names give way to the path only down to
+// MIN_NAME_WIDTH, past that the row is cut at the terminal edge.
+func (m *Application) namePadding() int {
+	if m.width == 0 {
+		return m.maxnamelen
+	}
+	return max(min(m.maxnamelen, m.listWidth()-ROW_FIXED_WIDTH), min(m.maxnamelen, MIN_NAME_WIDTH))
+}
+
+// pathWidth: room left for the path of the selected row
+func (m *Application) pathWidth(namepadding int) int {
+	if m.width == 0 {
+		return -1
+	}
+	return max(m.listWidth()-ROW_FIXED_WIDTH-namepadding, 0)
+}
+
+// resize: derives the row count from the terminal height minus every other pane, so the
+// whole view keeps the same height whatever is shown
+func (m *Application) resize() {
+	m.detailRows = DETAIL_HEIGHT
+	if m.height == 0 {
+		return
+	}
+	reserved := 2 + lineCount(m.generateFooter()) // blank line, separator and footer
+	if m.outputPane != "" {
+		reserved += 1 + lineCount(m.outputPane)
+	}
+	if toasts := m.generateToastsString(); toasts != "" {
+		reserved += 1 + lineCount(toasts)
+	}
+	reserved += lineCount(m.generateStatusString())
+	if m.isDetailBottom() {
+		m.detailRows = min(DETAIL_HEIGHT, max((m.height-reserved-1)/DETAIL_SHARE, 0))
+		reserved += 1 + m.detailRows
+	}
+	m.maxrows = max(m.height-reserved, MIN_ROWS)
+}
+
+// reflow: sizes the list and scrolls both viewports to their cursor. called after every
+// change that may move a cursor or resize a pane.
+func (m *Application) reflow() {
+	m.resize()
+	m.listView.follow(m.cursor, m.maxrows, len(m.workspaces))
+	m.filterView.follow(m.filterCursor, m.maxrows, len(m.filteredWorkspaces))
+}
+
+// separator: divider below the list, carrying the scroll position of the active list
+func (m *Application) separator() string {
+	position := m.listView.indicator(m.maxrows, len(m.workspaces))
+	if m.isFilterActive {
+		position = m.filterView.indicator(m.maxrows, len(m.filteredWorkspaces))
+	}
+	if n := len(m.marked); n > 0 {
+		marked := theme.Render(theme.MARKED, fmt.Sprintf("%d marked", n))
+		if position == "" {
+			position = marked
+		} else {
+			position = marked + " · " + position
+		}
+	}
+	if position == "" {
+		return "----------\n"
+	}
+	return "---------- " + position + "\n"
+}
+
+// activeRenderer: renderer of the active mode, used to reflow after a resize
+func (m *Application) activeRenderer() tea.Cmd {
+	switch m.mode {
+	case modes.FILTER:
+		return m.filterRenderer
+	case modes.SELECT_COMMAND:
+		return m.commandSelectRenderer
+	case modes.HELP:
+		return m.helpRenderer
+	case modes.MESSAGE_LOG:
+		return m.messageLogRenderer
+	case modes.DOCTOR:
+		return m.doctorRenderer
+	default:
+		return m.defaultRenderer
+	}
+}
+
+// isFullScreen: modes that take over the whole screen instead of the list
+func (m *Application) isFullScreen() bool {
+	return m.mode == modes.HELP || m.mode == modes.MESSAGE_LOG || m.mode == modes.DOCTOR
+}
+
+// fitView: cuts every line at the terminal width and pads the view to the terminal height.
+// a view that shrinks between two renders otherwise leaves stale lines behind.
+func (m *Application) fitView(view string) string {
+	if m.height == 0 {
+		return view
+	}
+	lines := strings.Split(strings.TrimSuffix(view, "\n"), "\n")
+	if len(lines) > m.height {
+		lines = lines[:m.height]
+	}
+	for i := range lines {
+		lines[i] = ansi.Truncate(lines[i], m.width, "")
+	}
+	for len(lines) < m.height {
+		lines = append(lines, "")
+	}
+	return strings.Join(lines, "\n")
+}
@@ -3,13 +3,15 @@ package models
 import (
 	"context"
 	"fmt"
+	"log/slog"
 	"slices"
 	"time"
 	"workspaces-cli/models/db"
+	"workspaces-cli/pkg/clipboard"
+	"workspaces-cli/pkg/config"
 	"workspaces-cli/pkg/editors"
+	"workspaces-cli/pkg/theme"
 	"workspaces-cli/pkg/workspaces"
-
-	"golang.design/x/clipboard"
 )
 
 const (
@@ -21,28 +23,21 @@ const (
 	DURATION_THIRTY_DAYS time.Duration = 30 * DURATION_ONE_DAY
 )
 
-var (
-	modtimeColors map[time.Duration]string = map[time.Duration]string{
-		DURATION_ONE_DAY:     "34", // blue
-		DURATION_ONE_WEEK:    "32", // green
-		DURATION_THIRTY_DAYS: "33", // yello
-	}
-)
-
-func modtimeColor(t time.Time) string {
+// modtimeStyle: age bucket of t
+func modtimeStyle(t time.Time) theme.StyleName {
 	switch t := time.Since(t); {
 	case t < DURATION_ONE_DAY:
-		return modtimeColors[DURATION_ONE_DAY]
+		return theme.AGE_DAY
 	case t < DURATION_ONE_WEEK:
-		return modtimeColors[DURATION_ONE_WEEK]
+		return theme.AGE_WEEK
 	case t < DURATION_THIRTY_DAYS:
-		return modtimeColors[DURATION_THIRTY_DAYS]
+		return theme.AGE_MONTH
 	}
-	return "31"
+	return theme.AGE_OLD
 }
 
 func modtimeColorize(t time.Time) string {
-	return fmt.Sprintf("\033[%sm%s\033[0m", modtimeColor(t), t.In(time.Local).Format(time.DateOnly))
+	return theme.Render(modtimeStyle(t), t.In(time.Local).Format(time.DateOnly))
 }
 
 func sortWorkspaces(w []workspaces.Workspace) []workspaces.Workspace {
@@ -58,17 +53,39 @@ func sortWorkspaces(w []workspaces.Workspace) []workspaces.Workspace {
 	return ww
 }
 
-func NewModel(ctx context.Context, w []workspaces.Workspace, dbfile string, editor editors.Editor) (*Application, error) {
-	if err := clipboard.Init(); err != nil {
-		return nil, fmt.Errorf("initialize clipboard: %w", err)
+// NewModel: the application takes over the store and closes it in Cleanup
+func NewModel(ctx context.Context, root string, w []workspaces.Workspace, store db.Store, editor editors.Editor, cfg config.Config) (*Application, error) {
+	t, err := theme.New(cfg.Theme, cfg.Themes, theme.DetectProfile())
+	if err != nil {
+		return nil, fmt.Errorf("theme: %w", err)
 	}
-	if err := db.Open(ctx, dbfile); err != nil {
-		return nil, fmt.Errorf("connect db: %w", err)
+	theme.Use(t)
+	if err := validateCommands(cfg.Commands); err != nil {
+		return nil, fmt.Errorf("commands: %w", err)
 	}
-	// TODO: terminal height for maxrows
-	return &Application{
+	k, err := newKeymap(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("keybindings: %w", err)
+	}
+	m := &Application{
+		ctx:        ctx,
+		store:      store,
 		workspaces: sortWorkspaces(w),
-		maxrows:    10,
-		commands:   []string{"add_checkpoint", "view_checkpoints"},
-		editor:     editor}, nil
+		maxrows:    10, // until the terminal size is known
+		config:     cfg,
+		keymap:     k,
+		details:    map[string]detail{},
+		marked:     map[string]bool{},
+		editor:     editor}
+	cb, err := clipboard.New(cfg.Clipboard)
+	if err != nil {
+		m.notify(SEVERITY_WARNING, err.Error())
+	}
+	slog.DebugContext(ctx, "clipboard", "backend", cb.Name())
+	m.clipboard = cb
+	if m.watcher, err = workspaces.Watch(root, WATCH_DEBOUNCE); err != nil {
+		// the list still works, it just won't follow changes on disk
+		m.watchError(err)
+	}
+	return m, nil
 }
@@ -6,4 +6,27 @@ const (
 	DEFAULT int = iota
 	FILTER
 	SELECT_COMMAND
+	HELP
+	MESSAGE_LOG
+	COPY
+	PROMPT
+	DOCTOR
 )
+
+var (
+	names map[InputMode]string = map[InputMode]string{
+		DEFAULT:        "default",
+		FILTER:         "filter",
+		SELECT_COMMAND: "command",
+		HELP:           "help",
+		MESSAGE_LOG:    "log",
+		COPY:           "copy",
+		PROMPT:         "prompt",
+		DOCTOR:         "doctor",
+	}
+)
+
+// Name: mode name as used by the keybindings config
+func Name(m InputMode) string {
+	return names[m]
+}
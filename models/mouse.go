@@ -0,0 +1,146 @@
+package models
+
+import (
+	"context"
+	"time"
+	"workspaces-cli/models/modes"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+const (
+	DOUBLE_CLICK_INTERVAL time.Duration = 400 * time.Millisecond
+	WHEEL_ROWS            int           = 3
+)
+
+// click: last left click, to tell double clicks apart
+type click struct {
+	x, y int
+	at   time.Time
+}
+
+// isDoubleClick: records the click and reports whether it completes a double click
+func (m *Application) isDoubleClick(msg tea.MouseMsg) bool {
+	now := time.Now()
+	double := m.lastClick.y == msg.Y && now.Sub(m.lastClick.at) < DOUBLE_CLICK_INTERVAL
+	m.lastClick = click{x: msg.X, y: msg.Y, at: now}
+	if double {
+		m.lastClick = click{}
+	}
+	return double
+}
+
+// footerTop: first line of the footer, below the list and whichever panes are shown
+func (m *Application) footerTop() int {
+	top := m.maxrows + 2 // blank line and separator
+	if m.outputPane != "" {
+		top += 1 + lineCount(m.outputPane)
+	}
+	if m.isDetailBottom() {
+		top += 1 + m.detailRows
+	}
+	if toasts := m.generateToastsString(); toasts != "" {
+		top += 1 + lineCount(toasts)
+	}
+	top += lineCount(m.generateStatusString())
+	return top
+}
+
+// scrollList: moves the active viewport by n rows, dragging the cursor along only when it
+// would leave the view
+func (m *Application) scrollList(n int) {
+	if m.isFilterActive {
+		m.filterView.offset = max(min(m.filterView.offset+n, len(m.filteredWorkspaces)-m.maxrows), 0)
+		m.filterCursor = max(min(m.filterCursor, m.filterView.offset+m.maxrows-1), m.filterView.offset)
+		return
+	}
+	m.listView.offset = max(min(m.listView.offset+n, len(m.workspaces)-m.maxrows), 0)
+	m.cursor = max(min(m.cursor, m.listView.offset+m.maxrows-1), m.listView.offset)
+}
+
+func (m *Application) listRenderer() tea.Cmd {
+	if m.isFilterActive {
+		return m.filterRenderer
+	}
+	return m.defaultRenderer
+}
+
+// clickList: selects the clicked row, a double click opens it
+func (m *Application) clickList(ctx context.Context, msg tea.MouseMsg) tea.Cmd {
+	if m.isFilterActive {
+		i := m.filterView.offset + msg.Y
+		if i >= len(m.filteredWorkspaces) {
+			return nil
+		}
+		m.filterCursor = i
+	} else {
+		i := m.listView.offset + msg.Y
+		if i >= len(m.workspaces) {
+			return nil
+		}
+		m.cursor = i
+	}
+	if m.isDoubleClick(msg) {
+		if m.mode == modes.FILTER {
+			return tea.Batch(m.runAction(ctx, modes.FILTER, "accept"), m.runAction(ctx, modes.DEFAULT, "open_workspace"))
+		}
+		return m.runAction(ctx, modes.DEFAULT, "open_workspace")
+	}
+	return tea.Batch(m.listRenderer(), m.detailLoader(ctx))
+}
+
+// clickFooter: in the palette a click selects a command and a double click runs it,
+// elsewhere the footer hints run their action
+func (m *Application) clickFooter(ctx context.Context, msg tea.MouseMsg) tea.Cmd {
+	line := msg.Y - m.footerTop()
+	switch m.mode {
+	case modes.SELECT_COMMAND:
+		i := line - 1 // palette input
+		if i < 0 || i >= len(m.paletteCommands) {
+			return nil
+		}
+		m.commandCursor = i
+		if m.isDoubleClick(msg) {
+			return m.activeCommandHandler(ctx)
+		}
+		return m.commandSelectRenderer
+	case modes.COPY:
+		i := line - 1 // menu title
+		if i < 0 || i >= len(copyFormats) {
+			return nil
+		}
+		m.copyCursor = i
+		if m.isDoubleClick(msg) {
+			return m.runAction(ctx, modes.COPY, "execute")
+		}
+		return m.defaultRenderer
+	case modes.FILTER:
+		line-- // filter input
+	}
+	hints := m.visibleFooterHints()
+	if line < 0 || line >= len(hints) {
+		return nil
+	}
+	return m.runAction(ctx, m.mode, hints[line])
+}
+
+func (m *Application) handleMouseMsg(ctx context.Context, msg tea.MouseMsg) (tea.Model, tea.Cmd) {
+	var cmd tea.Cmd
+	switch {
+	case m.isFullScreen():
+	case msg.Button == tea.MouseButtonWheelUp && m.mode != modes.SELECT_COMMAND:
+		m.scrollList(-WHEEL_ROWS)
+		cmd = tea.Batch(m.listRenderer(), m.detailLoader(ctx))
+	case msg.Button == tea.MouseButtonWheelDown && m.mode != modes.SELECT_COMMAND:
+		m.scrollList(WHEEL_ROWS)
+		cmd = tea.Batch(m.listRenderer(), m.detailLoader(ctx))
+	case msg.Button == tea.MouseButtonLeft && msg.Action == tea.MouseActionPress:
+		if msg.Y < m.maxrows && (!m.isDetailSide() || msg.X < m.listWidth()) && m.mode != modes.SELECT_COMMAND {
+			cmd = m.clickList(ctx, msg)
+		} else if msg.Y >= m.footerTop() {
+			cmd = m.clickFooter(ctx, msg)
+		}
+	}
+	m.reflow()
+	return m, cmd
+}
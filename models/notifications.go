@@ -0,0 +1,100 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"workspaces-cli/models/modes"
+	"workspaces-cli/pkg/theme"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+type severity int
+
+const (
+	SEVERITY_INFO severity = iota
+	SEVERITY_WARNING
+	SEVERITY_ERROR
+	SEVERITY_FATAL // the program can't go on, quits after rendering the error
+)
+
+const (
+	MAX_TOASTS int = 3 // older toasts stay in the message log only
+)
+
+var (
+	severityLabels map[severity]string = map[severity]string{
+		SEVERITY_INFO:    "info",
+		SEVERITY_WARNING: "warning",
+		SEVERITY_ERROR:   "error",
+		SEVERITY_FATAL:   "fatal",
+	}
+	severityStyles map[severity]theme.StyleName = map[severity]theme.StyleName{
+		SEVERITY_INFO:    theme.LABEL,
+		SEVERITY_WARNING: theme.WARNING,
+		SEVERITY_ERROR:   theme.ERROR,
+		SEVERITY_FATAL:   theme.ERROR,
+	}
+)
+
+type notification struct {
+	severity severity
+	message  string
+	at       time.Time
+}
+
+func (n notification) String() string {
+	return theme.Render(severityStyles[n.severity], fmt.Sprintf("[%s] %s", severityLabels[n.severity], n.message))
+}
+
+// notify: shows a toast until dismissed and keeps the message in the log
+func (m *Application) notify(s severity, message string) {
+	n := notification{severity: s, message: message, at: time.Now()}
+	m.messages = append(m.messages, n)
+	m.toasts = append(m.toasts, n)
+	if len(m.toasts) > MAX_TOASTS {
+		m.toasts = m.toasts[len(m.toasts)-MAX_TOASTS:]
+	}
+}
+
+func (m *Application) dismissToasts() {
+	m.toasts = nil
+}
+
+func (m *Application) generateToastsString() string {
+	if len(m.toasts) == 0 {
+		return ""
+	}
+	b := strings.Builder{}
+	for i := range m.toasts {
+		b.WriteString(" " + m.toasts[i].String() + "\n")
+	}
+	if keys := m.keymap.KeysFor(modes.Name(modes.DEFAULT), "dismiss"); len(keys) > 0 {
+		b.WriteString(theme.Render(theme.FOOTER, fmt.Sprintf("   type '%s' to dismiss", keys[0])) + "\n")
+	}
+	return b.String()
+}
+
+// generateMessageLogString: every notification of the session, oldest first, starting at
+// the scroll offset
+func (m *Application) generateMessageLogString() string {
+	b := strings.Builder{}
+	b.WriteString(theme.Render(theme.PROMPT, "MESSAGES") + "\n")
+	if len(m.messages) == 0 {
+		b.WriteString(theme.Render(theme.FOOTER, "   no messages") + "\n")
+	}
+	for i := m.logView.offset; i < len(m.messages) && i < m.logView.offset+m.maxrows; i++ {
+		b.WriteString(fmt.Sprintf(" %s %s\n", theme.Render(theme.LABEL, m.messages[i].at.Format(time.TimeOnly)), m.messages[i]))
+	}
+	return b.String()
+}
+
+func (m *Application) messageLogRenderer() tea.Msg {
+	return renderpanescmd{main: m.generateMessageLogString(), footer: m.generateFooter()}
+}
+
+// scrollMessageLog: moves the log by n lines
+func (m *Application) scrollMessageLog(n int) {
+	m.logView.offset = max(min(m.logView.offset+n, len(m.messages)-m.maxrows), 0)
+}
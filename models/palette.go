@@ -0,0 +1,92 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"workspaces-cli/models/modes"
+	"workspaces-cli/pkg/theme"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// command: entry of the command palette. handlers receive the model at execution time
+// since the model is copied on every update.
+type command struct {
+	name        string
+	description string
+	available   func(m *Application) bool // nil when the command is always available
+	handler     func(m *Application, ctx context.Context) tea.Cmd
+}
+
+var (
+	commandRegistry []command
+)
+
+// registerCommand: called from init by the files implementing commands
+func registerCommand(c command) {
+	if i := slices.IndexFunc(commandRegistry, func(r command) bool { return r.name == c.name }); i >= 0 {
+		panic(fmt.Sprintf("command registered twice: '%s'", c.name))
+	}
+	commandRegistry = append(commandRegistry, c)
+}
+
+func hasSelectedWorkspace(m *Application) bool {
+	return m.selectedWorkspace() != nil
+}
+
+func (c *command) isAvailable(m *Application) bool {
+	return c.available == nil || c.available(m)
+}
+
+// applyCommandFilter: narrows the available commands down to the ones matching the
+// palette input, best matches first. names weigh more than descriptions.
+func (m *Application) applyCommandFilter() {
+	type scored struct {
+		c     command
+		score int
+	}
+	matches := make([]scored, 0, len(m.commands))
+	for i := range m.commands {
+		if s, ok := fuzzyScore(m.commandFilterValue, m.commands[i].name); ok {
+			matches = append(matches, scored{m.commands[i], s})
+		} else if s, ok := fuzzyScore(m.commandFilterValue, m.commands[i].description); ok {
+			matches = append(matches, scored{m.commands[i], s + len(m.commands[i].name)})
+		}
+	}
+	slices.SortStableFunc(matches, func(a, b scored) int { return a.score - b.score })
+	m.paletteCommands = make([]command, len(matches))
+	for i := range matches {
+		m.paletteCommands[i] = matches[i].c
+	}
+	if m.commandCursor > m.getCommandCursorMax() {
+		m.commandCursor = 0
+	}
+}
+
+func (m *Application) generatePaletteString() string {
+	namepadding := 0
+	for i := range m.paletteCommands {
+		namepadding = max(namepadding, len(m.paletteCommands[i].name))
+	}
+	b := strings.Builder{}
+	b.WriteString(theme.Render(theme.PROMPT, fmt.Sprintf("↳ COMMAND > %s", m.commandFilterValue)) + "\n")
+	if len(m.paletteCommands) == 0 {
+		b.WriteString(theme.Render(theme.FOOTER, "   no matching commands") + "\n")
+	}
+	for i := range m.paletteCommands {
+		c := &m.paletteCommands[i]
+		key := ""
+		if keys := m.keymap.KeysFor(modes.Name(modes.DEFAULT), c.name); len(keys) > 0 {
+			key = theme.Render(theme.KEY, fmt.Sprintf("[%s]", strings.Join(keys, "/")))
+		}
+		line := fmt.Sprintf("%-*s   %s %s", namepadding, c.name, theme.Render(theme.FOOTER, c.description), key)
+		if m.commandCursor == i {
+			b.WriteString(" > " + line + "\n")
+		} else {
+			b.WriteString("   " + line + "\n")
+		}
+	}
+	return b.String()
+}
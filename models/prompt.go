@@ -0,0 +1,64 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"workspaces-cli/models/modes"
+	"workspaces-cli/pkg/theme"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// prompt: one line of input asked for by a command. submit runs with the entered value
+// once the prompt is accepted.
+type prompt struct {
+	label  string
+	value  string
+	submit func(m *Application, ctx context.Context, value string) tea.Cmd
+}
+
+func init() {
+	registerAction(modes.PROMPT, action{"cancel", "close the prompt", func(m *Application, ctx context.Context) tea.Cmd {
+		m.resetMode()
+		return m.defaultRenderer
+	}})
+	registerAction(modes.PROMPT, action{"accept", "submit the prompt", func(m *Application, ctx context.Context) tea.Cmd {
+		p := m.prompt
+		m.resetMode()
+		return tea.Batch(m.defaultRenderer, p.submit(m, ctx, p.value))
+	}})
+	registerAction(modes.PROMPT, action{"delete_char", "delete the last prompt character", func(m *Application, ctx context.Context) tea.Cmd {
+		if l := len(m.prompt.value); l > 0 {
+			m.prompt.value = m.prompt.value[:l-1]
+		}
+		return m.defaultRenderer
+	}})
+}
+
+func (m *Application) startPrompt(label string, submit func(m *Application, ctx context.Context, value string) tea.Cmd) {
+	m.startMode(modes.PROMPT)
+	m.prompt = prompt{label: label, submit: submit}
+}
+
+// confirmed: a yes to a prompt asking y/N, no is the default
+func confirmed(value string) bool {
+	answer := strings.ToLower(strings.TrimSpace(value))
+	return answer == "y" || answer == "yes"
+}
+
+func (m *Application) promptMode_handleKeyMsg(ctx context.Context, key tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if cmd, ok := m.resolveKey(ctx, key); ok {
+		return m, cmd
+	}
+	switch key.Type {
+	case tea.KeyRunes, tea.KeySpace:
+		m.prompt.value += key.String()
+		return m, m.defaultRenderer
+	}
+	return m, nil
+}
+
+func (m *Application) generatePromptString() string {
+	return theme.Render(theme.PROMPT, fmt.Sprintf("↳ %s > %s", m.prompt.label, m.prompt.value)) + "\n"
+}
@@ -0,0 +1,211 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"workspaces-cli/models/modes"
+	"workspaces-cli/pkg/theme"
+	"workspaces-cli/pkg/workspaces"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func init() {
+	for _, mode := range []modes.InputMode{modes.DEFAULT, modes.FILTER} {
+		registerAction(mode, action{"toggle_mark", "mark or unmark the selected workspace", func(m *Application, ctx context.Context) tea.Cmd {
+			m.toggleMark()
+			return m.activeRenderer()
+		}})
+		registerAction(mode, action{"mark_all", "mark every listed workspace", func(m *Application, ctx context.Context) tea.Cmd {
+			m.markAll()
+			return m.activeRenderer()
+		}})
+		registerAction(mode, action{"invert_marks", "invert the marks of the listed workspaces", func(m *Application, ctx context.Context) tea.Cmd {
+			m.invertMarks()
+			return m.activeRenderer()
+		}})
+		registerAction(mode, action{"clear_marks", "unmark every workspace", func(m *Application, ctx context.Context) tea.Cmd {
+			clear(m.marked)
+			return m.activeRenderer()
+		}})
+	}
+	registerCommand(command{
+		name:        "tag",
+		description: "tag the marked or selected workspaces",
+		available:   hasSelectedWorkspace,
+		handler:     (*Application).tagHandler,
+	})
+	registerCommand(command{
+		name:        "archive",
+		description: fmt.Sprintf("move the marked or selected workspaces to %s", workspaces.ARCHIVE_DIR),
+		available:   hasSelectedWorkspace,
+		handler:     (*Application).archiveHandler,
+	})
+}
+
+// listedWorkspaces: the workspaces the list shows, narrowed by the filter when it is active
+func (m *Application) listedWorkspaces() []*workspaces.Workspace {
+	if m.isFilterActive {
+		return m.filteredWorkspaces
+	}
+	ws := make([]*workspaces.Workspace, len(m.workspaces))
+	for i := range m.workspaces {
+		ws[i] = &m.workspaces[i]
+	}
+	return ws
+}
+
+func (m *Application) isMarked(w workspaces.Workspace) bool {
+	return m.marked[w.Path()]
+}
+
+func (m *Application) toggleMark() {
+	w := m.selectedWorkspace()
+	if w == nil {
+		return
+	}
+	if m.marked[w.Path()] {
+		delete(m.marked, w.Path())
+	} else {
+		m.marked[w.Path()] = true
+	}
+}
+
+func (m *Application) markAll() {
+	for _, w := range m.listedWorkspaces() {
+		m.marked[w.Path()] = true
+	}
+}
+
+func (m *Application) invertMarks() {
+	for _, w := range m.listedWorkspaces() {
+		if m.marked[w.Path()] {
+			delete(m.marked, w.Path())
+		} else {
+			m.marked[w.Path()] = true
+		}
+	}
+}
+
+// targets: what batch actions run on. the marked workspaces in list order, or the
+// selected one when nothing is marked.
+func (m *Application) targets() []workspaces.Workspace {
+	ws := []workspaces.Workspace{}
+	for i := range m.workspaces {
+		if m.isMarked(m.workspaces[i]) {
+			ws = append(ws, m.workspaces[i])
+		}
+	}
+	if len(ws) > 0 {
+		return ws
+	}
+	if w := m.selectedWorkspace(); w != nil {
+		ws = append(ws, *w)
+	}
+	return ws
+}
+
+// targetsLabel: the targets as named in status messages
+func targetsLabel(ws []workspaces.Workspace) string {
+	if len(ws) == 1 {
+		return ws[0].DirEntry.Name()
+	}
+	return fmt.Sprintf("%d workspaces", len(ws))
+}
+
+func (m *Application) tagHandler(ctx context.Context) tea.Cmd {
+	ws := m.targets()
+	m.startPrompt(fmt.Sprintf("TAG %s", targetsLabel(ws)), func(m *Application, ctx context.Context, value string) tea.Cmd {
+		tag := strings.TrimSpace(value)
+		if tag == "" {
+			return nil
+		}
+		for i := range ws {
+			m.invalidateDetail(ws[i])
+		}
+		return func() tea.Msg {
+			errs := []error{}
+			for i := range ws {
+				if err := m.store.AddTag(ctx, ws[i], tag); err != nil {
+					errs = append(errs, fmt.Errorf("%s: %w", ws[i].DirEntry.Name(), err))
+				}
+			}
+			if err := errors.Join(errs...); err != nil {
+				return errormessage{err: fmt.Errorf("tag: %w", err)}
+			}
+			return statusmessagecmd{style: theme.SUCCESS, text: fmt.Sprintf("🏷  tagged %s with '%s'", targetsLabel(ws), tag)}
+		}
+	})
+	return m.defaultRenderer
+}
+
+// archiveHandler: asks before archiving the targets, marks may include many workspaces
+// the filter hides
+func (m *Application) archiveHandler(ctx context.Context) tea.Cmd {
+	ws := m.targets()
+	label := fmt.Sprintf("ARCHIVE %s to %s", targetsLabel(ws), workspaces.ARCHIVE_DIR)
+	listed := m.listedWorkspaces()
+	hidden := 0
+	for i := range ws {
+		if !slices.ContainsFunc(listed, func(w *workspaces.Workspace) bool { return w.Path() == ws[i].Path() }) {
+			hidden++
+		}
+	}
+	if hidden > 0 {
+		label += fmt.Sprintf(", %d not listed", hidden)
+	}
+	m.startPrompt(label+"? [y/N]", func(m *Application, ctx context.Context, value string) tea.Cmd {
+		if !confirmed(value) {
+			return m.showStatus(theme.WARNING, "📦 nothing archived")
+		}
+		return m.archive(ctx, ws)
+	})
+	return m.defaultRenderer
+}
+
+// archive: moves the workspaces out of the list. the directories are renamed, not
+// deleted, so moving them back restores them.
+func (m *Application) archive(ctx context.Context, ws []workspaces.Workspace) tea.Cmd {
+	errs := []error{}
+	moved := map[string]workspaces.Workspace{}
+	for i := range ws {
+		a, err := ws[i].Archive()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", ws[i].DirEntry.Name(), err))
+			continue
+		}
+		moved[ws[i].Path()] = a
+		m.invalidateDetail(ws[i])
+		delete(m.marked, ws[i].Path())
+		m.workspaces = slices.DeleteFunc(m.workspaces, func(w workspaces.Workspace) bool { return w.Path() == ws[i].Path() })
+	}
+	m.cursor = min(m.cursor, max(len(m.workspaces)-1, 0))
+	if m.isFilterActive {
+		m.applyFilter()
+		m.filterCursor = min(m.filterCursor, max(len(m.filteredWorkspaces)-1, 0))
+	}
+	cmds := []tea.Cmd{m.activeRenderer(), m.detailLoader(ctx)}
+	if err := errors.Join(errs...); err != nil {
+		cmds = append(cmds, func() tea.Msg { return errormessage{err: fmt.Errorf("archive: %w", err)} })
+	}
+	if len(moved) > 0 {
+		cmds = append(cmds, m.showStatus(theme.SUCCESS, fmt.Sprintf("📦 archived %d workspace(s) to %s", len(moved), workspaces.ARCHIVE_DIR)))
+		// the history follows the workspace, the doctor would take it for gone otherwise
+		cmds = append(cmds, func() tea.Msg {
+			errs := []error{}
+			for from, to := range moved {
+				if err := m.store.MoveWorkspace(ctx, from, to); err != nil {
+					errs = append(errs, fmt.Errorf("%s: %w", to.DirEntry.Name(), err))
+				}
+			}
+			if err := errors.Join(errs...); err != nil {
+				return errormessage{err: fmt.Errorf("archive: record new path: %w", err)}
+			}
+			return nil
+		})
+	}
+	return tea.Batch(cmds...)
+}
@@ -0,0 +1,51 @@
+package models
+
+import (
+	"time"
+	"workspaces-cli/pkg/theme"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// status: transient message shown on the status line for MESSAGE_TIMEOUT
+type status struct {
+	id    int
+	style theme.StyleName
+	text  string
+}
+
+// showStatus: queues a message for the status line. the returned cmd starts the expiry
+// timer when the message is shown right away, queued messages get theirs once they are up.
+func (m *Application) showStatus(style theme.StyleName, text string) tea.Cmd {
+	m.statusSeq++
+	m.statusQueue = append(m.statusQueue, status{id: m.statusSeq, style: style, text: text})
+	if len(m.statusQueue) > 1 {
+		return nil
+	}
+	return m.statusTimer()
+}
+
+func (m *Application) statusTimer() tea.Cmd {
+	id := m.statusQueue[0].id
+	return tea.Tick(MESSAGE_TIMEOUT, func(time.Time) tea.Msg { return statusexpiredcmd(id) })
+}
+
+// expireStatus: drops the shown message if it is the one the timer was started for and
+// starts the timer of the next one
+func (m *Application) expireStatus(id int) tea.Cmd {
+	if len(m.statusQueue) == 0 || m.statusQueue[0].id != id {
+		return nil
+	}
+	m.statusQueue = m.statusQueue[1:]
+	if len(m.statusQueue) == 0 {
+		return nil
+	}
+	return m.statusTimer()
+}
+
+func (m *Application) generateStatusString() string {
+	if len(m.statusQueue) == 0 {
+		return ""
+	}
+	return " " + theme.Render(m.statusQueue[0].style, m.statusQueue[0].text) + "\n"
+}
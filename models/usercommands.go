@@ -0,0 +1,151 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"slices"
+	"strings"
+	"time"
+	"workspaces-cli/models/db"
+	"workspaces-cli/models/modes"
+	"workspaces-cli/pkg/config"
+	"workspaces-cli/pkg/debuglog"
+	"workspaces-cli/pkg/theme"
+	"workspaces-cli/pkg/workspaces"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+const (
+	OUTPUT_MAX_LINES int = 20 // background command output is tailed to this many lines
+)
+
+// loadCommands: available registered commands, then the global user commands, then the
+// ones defined by the selected workspace
+func (m *Application) loadCommands() {
+	m.commands = make([]command, 0, len(commandRegistry))
+	for i := range commandRegistry {
+		if commandRegistry[i].isAvailable(m) {
+			m.commands = append(m.commands, commandRegistry[i])
+		}
+	}
+	for i := range m.config.Commands {
+		m.commands = append(m.commands, userCommand(m.config.Commands[i]))
+	}
+	w := m.selectedWorkspace()
+	if w == nil {
+		return
+	}
+	wc, err := config.LoadWorkspace(w.Path())
+	if err != nil {
+		m.notify(SEVERITY_WARNING, fmt.Sprintf("workspace commands: %s", err))
+		return
+	}
+	for i := range wc.Commands {
+		// the global command, or the builtin, keeps the name
+		name := wc.Commands[i].Name
+		if isBuiltinName(name) || slices.ContainsFunc(m.config.Commands, func(c config.Command) bool { return c.Name == name }) {
+			m.notify(SEVERITY_WARNING, fmt.Sprintf("workspace commands: '%s' is already defined, skipped", name))
+			continue
+		}
+		m.commands = append(m.commands, userCommand(wc.Commands[i]))
+	}
+}
+
+func userCommand(c config.Command) command {
+	description := c.Run
+	if c.Background {
+		description += " (background)"
+	}
+	return command{
+		name:        c.Name,
+		description: description,
+		available:   hasSelectedWorkspace,
+		handler: func(m *Application, ctx context.Context) tea.Cmd {
+			ws := m.targets()
+			if len(ws) == 0 {
+				return nil
+			}
+			if c.Background {
+				return m.runBackgroundCommand(ctx, c, ws)
+			}
+			cmds := make([]tea.Cmd, len(ws))
+			for i := range ws {
+				cmds[i] = m.runForegroundCommand(ctx, c, ws[i])
+			}
+			return tea.Sequence(cmds...)
+		},
+	}
+}
+
+func shellCommand(ctx context.Context, c config.Command, w workspaces.Workspace) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, "sh", "-c", c.Run)
+	cmd.Dir = w.Path()
+	return cmd
+}
+
+// runForegroundCommand: hands the terminal over to the command until it exits
+func (m *Application) runForegroundCommand(ctx context.Context, c config.Command, w workspaces.Workspace) tea.Cmd {
+	cmd := shellCommand(ctx, c, w)
+	start := time.Now()
+	m.invalidateDetail(w)
+	return tea.ExecProcess(cmd, func(err error) tea.Msg {
+		debuglog.Process(ctx, cmd, start, err)
+		m.recordActivity(ctx, w, db.ACTIVITY_COMMAND)
+		if err != nil {
+			return statusmessagecmd{style: theme.ERROR, text: fmt.Sprintf("❌ '%s' in %s failed: %s", c.Name, w.DirEntry.Name(), err)}
+		}
+		return statusmessagecmd{style: theme.SUCCESS, text: fmt.Sprintf("✅ '%s' in %s finished", c.Name, w.DirEntry.Name())}
+	})
+}
+
+// runBackgroundCommand: runs the command in each workspace, one after the other, while
+// the list stays usable. their combined output ends up in the output pane.
+func (m *Application) runBackgroundCommand(ctx context.Context, c config.Command, ws []workspaces.Workspace) tea.Cmd {
+	m.resetMode()
+	m.outputPane = theme.Render(theme.FOOTER, fmt.Sprintf("⏳ running '%s' in %s", c.Name, targetsLabel(ws))) + "\n"
+	for i := range ws {
+		m.invalidateDetail(ws[i])
+	}
+	return tea.Batch(m.defaultRenderer, func() tea.Msg {
+		b := strings.Builder{}
+		errs := []error{}
+		for i := range ws {
+			cmd := shellCommand(ctx, c, ws[i])
+			start := time.Now()
+			out, err := cmd.CombinedOutput()
+			debuglog.Process(ctx, cmd, start, err)
+			m.recordActivity(ctx, ws[i], db.ACTIVITY_COMMAND)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", ws[i].DirEntry.Name(), err))
+			}
+			if len(ws) > 1 {
+				b.WriteString(theme.Render(theme.LABEL, "── "+ws[i].DirEntry.Name()) + "\n")
+			}
+			b.Write(out)
+		}
+		return commandoutputcmd{name: c.Name, workspace: targetsLabel(ws), output: b.String(), err: errors.Join(errs...)}
+	})
+}
+
+func (m *Application) generateCommandOutputString(msg commandoutputcmd) string {
+	b := strings.Builder{}
+	if msg.err != nil {
+		b.WriteString(theme.Render(theme.ERROR, fmt.Sprintf("❌ '%s' in %s: %s", msg.name, msg.workspace, msg.err)) + "\n")
+	} else {
+		b.WriteString(theme.Render(theme.SUCCESS, fmt.Sprintf("✅ '%s' in %s", msg.name, msg.workspace)) + "\n")
+	}
+	if out := strings.TrimRight(msg.output, "\n"); out != "" {
+		lines := strings.Split(out, "\n")
+		if len(lines) > OUTPUT_MAX_LINES {
+			lines = lines[len(lines)-OUTPUT_MAX_LINES:]
+		}
+		b.WriteString(strings.Join(lines, "\n") + "\n")
+	}
+	if keys := m.keymap.KeysFor(modes.Name(modes.DEFAULT), "close_output"); len(keys) > 0 {
+		b.WriteString(theme.Render(theme.FOOTER, fmt.Sprintf("   type '%s' to close the output", keys[0])) + "\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,26 @@
+package models
+
+import "fmt"
+
+// viewport: scroll offset of a list. the offset only moves as far as needed to keep the
+// cursor visible, so the selected row isn't pinned to the top.
+type viewport struct {
+	offset int
+}
+
+func (v *viewport) follow(cursor, rows, total int) {
+	if cursor < v.offset {
+		v.offset = cursor
+	} else if cursor >= v.offset+rows {
+		v.offset = cursor - rows + 1
+	}
+	v.offset = max(min(v.offset, total-rows), 0)
+}
+
+// indicator: range of visible rows, empty when everything fits
+func (v *viewport) indicator(rows, total int) string {
+	if total <= rows {
+		return ""
+	}
+	return fmt.Sprintf("%d-%d of %d", v.offset+1, min(v.offset+rows, total), total)
+}
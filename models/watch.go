@@ -0,0 +1,67 @@
+package models
+
+import (
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+	"workspaces-cli/pkg/workspaces"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+const (
+	WATCH_DEBOUNCE time.Duration = 250 * time.Millisecond
+)
+
+// waitForWorkspaces: resolves to the next change of the workspace root. Update asks for
+// the following one once the change is applied.
+func (m *Application) waitForWorkspaces() tea.Msg {
+	if m.watcher == nil {
+		return nil
+	}
+	select {
+	case ws, ok := <-m.watcher.Changes():
+		if !ok {
+			return nil
+		}
+		return workspaceschangedcmd{workspaces: ws}
+	case err := <-m.watcher.Errors():
+		return workspaceschangedcmd{err: err}
+	}
+}
+
+// setWorkspaces: swaps in the reloaded list, keeping both cursors and the marks on the
+// workspaces they were on
+func (m *Application) setWorkspaces(ws []workspaces.Workspace) {
+	selected := ""
+	if w := m.selectedWorkspace(); w != nil {
+		selected = w.Path()
+	}
+	m.workspaces = sortWorkspaces(ws)
+	slog.Debug("reloaded workspaces", "count", len(m.workspaces))
+	paths := make(map[string]bool, len(m.workspaces))
+	for i := range m.workspaces {
+		paths[m.workspaces[i].Path()] = true
+	}
+	for p := range m.marked {
+		if !paths[p] {
+			delete(m.marked, p)
+		}
+	}
+	if i := slices.IndexFunc(m.workspaces, func(w workspaces.Workspace) bool { return w.Path() == selected }); i >= 0 && !m.isFilterActive {
+		m.cursor = i
+	}
+	m.cursor = min(m.cursor, max(len(m.workspaces)-1, 0))
+	if m.isFilterActive {
+		m.applyFilter()
+		if i := slices.IndexFunc(m.filteredWorkspaces, func(w *workspaces.Workspace) bool { return w.Path() == selected }); i >= 0 {
+			m.filterCursor = i
+		}
+		m.filterCursor = min(m.filterCursor, max(len(m.filteredWorkspaces)-1, 0))
+	}
+}
+
+func (m *Application) watchError(err error) {
+	m.notify(SEVERITY_WARNING, fmt.Sprintf("watch workspaces: %s", err))
+}
@@ -0,0 +1,64 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+	"workspaces-cli/models/db"
+	"workspaces-cli/pkg/debuglog"
+	"workspaces-cli/pkg/theme"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func init() {
+	registerCommand(command{
+		name:        "copy_path",
+		description: "copy the selected workspace path to the clipboard",
+		available:   hasSelectedWorkspace,
+		handler:     (*Application).copyPathHandler,
+	})
+	registerCommand(command{
+		name:        "open_workspace",
+		description: "open the selected workspace with the open command",
+		available:   hasSelectedWorkspace,
+		handler:     (*Application).openWorkspaceHandler,
+	})
+	registerCommand(command{
+		name:        "toggle_details",
+		description: "show or hide the selected workspace details",
+		handler:     (*Application).toggleDetailHandler,
+	})
+}
+
+func (m *Application) copyPathHandler(ctx context.Context) tea.Cmd {
+	return m.copyToClipboard(ctx, copyFormatByName("path"))
+}
+
+func (m *Application) openWorkspaceHandler(ctx context.Context) tea.Cmd {
+	ws := m.targets()
+	cmds := []tea.Cmd{m.showStatus(theme.FOOTER, fmt.Sprintf("💻 opening %s", targetsLabel(ws)))}
+	for _, w := range ws {
+		m.invalidateDetail(w)
+		cmds = append(cmds, func() tea.Msg {
+			args := strings.Fields(m.config.OpenCommand)
+			cmd := exec.Command(args[0], append(args[1:], w.Path())...)
+			start := time.Now()
+			err := cmd.Run()
+			debuglog.Process(ctx, cmd, start, err)
+			if err != nil {
+				return errormessage{err: fmt.Errorf("open %s: %w", w.DirEntry.Name(), err)}
+			}
+			m.recordActivity(ctx, w, db.ACTIVITY_OPEN)
+			return nil
+		})
+	}
+	return tea.Batch(cmds...)
+}
+
+func (m *Application) toggleDetailHandler(ctx context.Context) tea.Cmd {
+	m.toggleDetail()
+	return tea.Batch(m.defaultRenderer, m.detailLoader(ctx))
+}
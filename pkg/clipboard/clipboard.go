@@ -0,0 +1,181 @@
+package clipboard
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	system "golang.design/x/clipboard"
+)
+
+const (
+	AUTO    string = "auto"
+	OSC52   string = "osc52"   // terminal escape sequence, works over ssh
+	WL_COPY string = "wl-copy" // wayland
+	XCLIP   string = "xclip"
+	XSEL    string = "xsel"
+	SYSTEM  string = "system" // golang.design/x/clipboard, needs a display
+	NONE    string = "none"
+)
+
+var (
+	ErrUnavailable = errors.New("no clipboard available")
+
+	Backends []string            = []string{AUTO, OSC52, WL_COPY, XCLIP, XSEL, SYSTEM, NONE}
+	commands map[string][]string = map[string][]string{
+		WL_COPY: {"wl-copy"},
+		XCLIP:   {"xclip", "-selection", "clipboard"},
+		XSEL:    {"xsel", "--clipboard", "--input"},
+	}
+)
+
+type Clipboard interface {
+	Name() string
+	Write(text string) error
+}
+
+// osc52: asks the terminal to set the clipboard, tmux needs the sequence wrapped to pass
+// it through
+type osc52 struct {
+	tmux bool
+}
+
+func (c osc52) Name() string {
+	return OSC52
+}
+
+func (c osc52) Write(text string) error {
+	seq := "\x1b]52;c;" + base64.StdEncoding.EncodeToString([]byte(text)) + "\x07"
+	if c.tmux {
+		seq = "\x1bPtmux;" + strings.ReplaceAll(seq, "\x1b", "\x1b\x1b") + "\x1b\\"
+	}
+	// the ui owns stdout, the sequence goes straight to the terminal
+	var w io.Writer = os.Stderr
+	if tty, err := os.OpenFile("/dev/tty", os.O_WRONLY, 0); err == nil {
+		defer tty.Close()
+		w = tty
+	}
+	_, err := io.WriteString(w, seq)
+	return err
+}
+
+// command: pipes the text to a clipboard tool
+type command struct {
+	name string
+	args []string
+}
+
+func (c command) Name() string {
+	return c.name
+}
+
+// Write: xclip and wl-copy fork a server holding the selection until another program
+// takes it. the server inherits whatever the command writes to, a pipe there would keep
+// Wait blocked all that time, so stdout is left closed and stderr goes to a file.
+func (c command) Write(text string) error {
+	stderr, err := os.CreateTemp("", "workspaces-cli-clipboard-")
+	if err != nil {
+		return fmt.Errorf("%s: %w", c.name, err)
+	}
+	defer os.Remove(stderr.Name())
+	defer stderr.Close()
+	cmd := exec.Command(c.args[0], c.args[1:]...)
+	cmd.Stdin = strings.NewReader(text)
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err != nil {
+		out, _ := os.ReadFile(stderr.Name())
+		return fmt.Errorf("%s: %w: %s", c.name, err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+type systemClipboard struct{}
+
+func (c systemClipboard) Name() string {
+	return SYSTEM
+}
+
+func (c systemClipboard) Write(text string) error {
+	// nil when the write failed, the library keeps the reason to itself
+	if system.Write(system.FmtText, []byte(text)) == nil {
+		return errors.New("system clipboard: write failed")
+	}
+	return nil
+}
+
+type none struct{}
+
+func (c none) Name() string {
+	return NONE
+}
+
+func (c none) Write(text string) error {
+	return ErrUnavailable
+}
+
+func hasCommand(name string) bool {
+	_, err := exec.LookPath(commands[name][0])
+	return err == nil
+}
+
+func newCommand(name string) Clipboard {
+	return command{name: name, args: commands[name]}
+}
+
+func newOSC52() Clipboard {
+	return osc52{tmux: os.Getenv("TMUX") != ""}
+}
+
+// Detect: the best backend for the environment. over ssh the local tools would write to
+// the remote clipboard, so the terminal is asked instead.
+func Detect() Clipboard {
+	if os.Getenv("SSH_TTY") != "" || os.Getenv("SSH_CONNECTION") != "" {
+		return newOSC52()
+	}
+	if os.Getenv("WAYLAND_DISPLAY") != "" && hasCommand(WL_COPY) {
+		return newCommand(WL_COPY)
+	}
+	if os.Getenv("DISPLAY") != "" {
+		for _, name := range []string{XCLIP, XSEL} {
+			if hasCommand(name) {
+				return newCommand(name)
+			}
+		}
+	}
+	if system.Init() == nil {
+		return systemClipboard{}
+	}
+	if t := os.Getenv("TERM"); t != "" && t != "dumb" {
+		return newOSC52()
+	}
+	return none{}
+}
+
+// New: the backend by name, detected for AUTO or an empty name. a backend that can't be
+// set up falls back to NONE along with the reason, copying must never keep the program
+// from starting.
+func New(name string) (Clipboard, error) {
+	switch name {
+	case "", AUTO:
+		return Detect(), nil
+	case OSC52:
+		return newOSC52(), nil
+	case WL_COPY, XCLIP, XSEL:
+		if !hasCommand(name) {
+			return none{}, fmt.Errorf("clipboard '%s': command not found", name)
+		}
+		return newCommand(name), nil
+	case SYSTEM:
+		if err := system.Init(); err != nil {
+			return none{}, fmt.Errorf("clipboard '%s': %w", name, err)
+		}
+		return systemClipboard{}, nil
+	case NONE:
+		return none{}, nil
+	}
+	return Detect(), fmt.Errorf("unknown clipboard '%s', expected one of %s", name, strings.Join(Backends, ", "))
+}
@@ -0,0 +1,138 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+	"workspaces-cli/pkg/theme"
+)
+
+const (
+	APP_NAME              string = "workspaces-cli"
+	CONFIG_FILE           string = "config.json"
+	WORKSPACE_CONFIG_FILE string = ".workspacescli.json" // per workspace config, read from the workspace directory
+
+	DEFAULT_OPEN_COMMAND string = "code"
+	DEFAULT_BACKUP_KEEP  int    = 10
+
+	DETAIL_BOTTOM string = "bottom" // detail pane between the list and the footer
+	DETAIL_SIDE   string = "side"   // detail pane right of the list
+)
+
+// Command: user defined shell command, executed with sh -c in the workspace directory
+type Command struct {
+	Name       string `json:"name"`
+	Run        string `json:"run"`
+	Background bool   `json:"background"` // capture output into a pane instead of handing over the terminal
+}
+
+type Config struct {
+	OpenCommand  string                       `json:"open_command"`  // workspaces are opened with this command followed by their path
+	Theme        string                       `json:"theme"`         // builtin theme or one of themes
+	Themes       map[string]theme.UserTheme   `json:"themes"`        // user themes by name
+	DisableMouse bool                         `json:"disable_mouse"` // for terminals that mis-handle mouse reporting
+	DetailPane   string                       `json:"detail_pane"`   // bottom or side, bottom when empty
+	Clipboard    string                       `json:"clipboard"`     // clipboard backend, detected when empty or "auto"
+	Store        string                       `json:"store"`         // sqlite, files or memory, sqlite when empty
+	StoreDir     string                       `json:"store_dir"`     // where the files store keeps its files, the data dir when empty
+	BackupDir    string                       `json:"backup_dir"`    // snapshots of the sqlite store, the data dir when empty
+	BackupKeep   int                          `json:"backup_keep"`   // snapshots kept, 0 turns the automatic ones off
+	SyncRemote   string                       `json:"sync_remote"`   // git url or path the sync repository is pushed to, none when empty
+	SyncDir      string                       `json:"sync_dir"`      // local clone of the sync repository, the data dir when empty
+	Commands     []Command                    `json:"commands"`
+	Keybindings  map[string]map[string]string `json:"keybindings"` // mode -> key sequence -> action
+}
+
+// WorkspaceConfig: the subset of the config a workspace can define for itself
+type WorkspaceConfig struct {
+	Commands []Command `json:"commands"`
+}
+
+func Dir() (string, error) {
+	d, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return path.Join(d, APP_NAME), nil
+}
+
+// StateDir: where logs and other files the user doesn't edit go, following XDG_STATE_HOME
+func StateDir() (string, error) {
+	if d := os.Getenv("XDG_STATE_HOME"); d != "" {
+		return path.Join(d, APP_NAME), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return path.Join(home, ".local", "state", APP_NAME), nil
+}
+
+// DataDir: where the files store keeps what the user would miss, following XDG_DATA_HOME
+func DataDir() (string, error) {
+	if d := os.Getenv("XDG_DATA_HOME"); d != "" {
+		return path.Join(d, APP_NAME), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return path.Join(home, ".local", "share", APP_NAME), nil
+}
+
+func readJSON(file string, v any) error {
+	data, err := os.ReadFile(file)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("parse '%s': %w", file, err)
+	}
+	return nil
+}
+
+func validateCommands(c []Command) error {
+	names := map[string]bool{}
+	for i := range c {
+		if c[i].Name == "" || c[i].Run == "" {
+			return fmt.Errorf("command %d: name and run are required", i)
+		}
+		if names[c[i].Name] {
+			return fmt.Errorf("command %d: '%s' is defined twice", i, c[i].Name)
+		}
+		names[c[i].Name] = true
+	}
+	return nil
+}
+
+// Load: reads the config file. a missing file is not an error and yields the defaults.
+func Load(file string) (Config, error) {
+	c := Config{OpenCommand: DEFAULT_OPEN_COMMAND, Theme: theme.DEFAULT_THEME, BackupKeep: DEFAULT_BACKUP_KEEP}
+	if err := readJSON(file, &c); err != nil {
+		return Config{}, err
+	}
+	if len(strings.Fields(c.OpenCommand)) == 0 {
+		return Config{}, fmt.Errorf("open_command is empty")
+	}
+	if c.BackupKeep < 0 {
+		return Config{}, fmt.Errorf("backup_keep is negative")
+	}
+	if c.DetailPane != "" && c.DetailPane != DETAIL_BOTTOM && c.DetailPane != DETAIL_SIDE {
+		return Config{}, fmt.Errorf("detail_pane must be %s or %s", DETAIL_BOTTOM, DETAIL_SIDE)
+	}
+	return c, validateCommands(c.Commands)
+}
+
+func LoadWorkspace(dir string) (WorkspaceConfig, error) {
+	var c WorkspaceConfig
+	if err := readJSON(path.Join(dir, WORKSPACE_CONFIG_FILE), &c); err != nil {
+		return WorkspaceConfig{}, err
+	}
+	return c, validateCommands(c.Commands)
+}
@@ -0,0 +1,74 @@
+package debuglog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path"
+	"time"
+)
+
+const (
+	ENV_VAR     string = "WORKSPACES_CLI_DEBUG" // "1", "text" or "json" turn the log on
+	LOG_FILE    string = "debug.log"
+	FORMAT_TEXT string = "text"
+	FORMAT_JSON string = "json"
+)
+
+// FromEnv: format requested through ENV_VAR, empty when the log is off
+func FromEnv() string {
+	switch v := os.Getenv(ENV_VAR); v {
+	case "", "0", "false":
+		return ""
+	case FORMAT_JSON:
+		return FORMAT_JSON
+	default:
+		return FORMAT_TEXT
+	}
+}
+
+// Setup: sends every record of the default logger to file, appending to it. the terminal
+// belongs to the ui, so without Setup records are dropped rather than printed.
+func Setup(file string, format string) (io.Closer, error) {
+	if err := os.MkdirAll(path.Dir(file), 0o755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(file, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	opts := &slog.HandlerOptions{Level: slog.LevelDebug}
+	var h slog.Handler
+	switch format {
+	case FORMAT_JSON:
+		h = slog.NewJSONHandler(f, opts)
+	case FORMAT_TEXT, "":
+		h = slog.NewTextHandler(f, opts)
+	default:
+		f.Close()
+		return nil, fmt.Errorf("unknown log format '%s'", format)
+	}
+	slog.SetDefault(slog.New(h))
+	return f, nil
+}
+
+func Disable() {
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+}
+
+// Process: records a finished process with its exit code
+func Process(ctx context.Context, cmd *exec.Cmd, start time.Time, err error) {
+	code := -1
+	if cmd.ProcessState != nil {
+		code = cmd.ProcessState.ExitCode()
+	}
+	var exiterr *exec.ExitError
+	if errors.As(err, &exiterr) {
+		err = nil // the exit code says it all
+	}
+	slog.DebugContext(ctx, "process", "args", cmd.Args, "dir", cmd.Dir, "exit_code", code, "duration", time.Since(start), "error", err)
+}
@@ -0,0 +1,177 @@
+package gitinfo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"workspaces-cli/pkg/debuglog"
+)
+
+func run(dir string, args ...string) (string, error) {
+	return Run(context.Background(), dir, args...)
+}
+
+// Run: git in dir, a failure carries git's own message
+func Run(ctx context.Context, dir string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", append([]string{"-C", dir}, args...)...)
+	start := time.Now()
+	out, err := cmd.Output()
+	debuglog.Process(ctx, cmd, start, err)
+	var exiterr *exec.ExitError
+	if errors.As(err, &exiterr) && len(exiterr.Stderr) > 0 {
+		return "", errors.New(strings.TrimSpace(string(exiterr.Stderr)))
+	} else if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+func IsRepository(dir string) bool {
+	_, err := run(dir, "rev-parse", "--git-dir")
+	return err == nil
+}
+
+// IsTopLevel: dir is the top level of a repository, not a directory somewhere inside one
+func IsTopLevel(dir string) bool {
+	top, err := run(dir, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return false
+	}
+	// git resolves symlinks in the path it prints
+	a, errA := filepath.EvalSymlinks(top)
+	b, errB := filepath.EvalSymlinks(dir)
+	return errA == nil && errB == nil && a == b
+}
+
+func Branch(dir string) (string, error) {
+	return run(dir, "rev-parse", "--abbrev-ref", "HEAD")
+}
+
+// RemoteURL: url of origin, or of the first remote when there is no origin
+func RemoteURL(dir string) (string, error) {
+	if url, err := run(dir, "remote", "get-url", "origin"); err == nil {
+		return url, nil
+	}
+	remotes, err := run(dir, "remote")
+	if err != nil {
+		return "", err
+	}
+	if remotes == "" {
+		return "", errors.New("no remotes")
+	}
+	first, _, _ := strings.Cut(remotes, "\n")
+	return run(dir, "remote", "get-url", first)
+}
+
+// NormalizeRemote: the host and path of a remote url, the same for its ssh and https
+// forms. git@github.com:me/repo.git and https://github.com/me/repo both give github.com/me/repo
+func NormalizeRemote(url string) string {
+	u := strings.TrimSpace(url)
+	if scheme, rest, ok := strings.Cut(u, "://"); ok && !strings.Contains(scheme, "/") {
+		u = rest
+	} else if host, path, ok := strings.Cut(u, ":"); ok && !strings.Contains(host, "/") {
+		// scp-like syntax, user@host:path
+		u = host + "/" + path
+	}
+	if user, rest, ok := strings.Cut(u, "@"); ok && !strings.Contains(user, "/") {
+		u = rest
+	}
+	u = strings.TrimSuffix(strings.TrimSuffix(u, "/"), ".git")
+	return strings.ToLower(u)
+}
+
+// State: the code in a repository at some point, what a checkpoint refers to
+type State struct {
+	Branch   string `json:"branch,omitempty"`
+	Head     string `json:"head,omitempty"`  // short hash, empty before the first commit
+	Dirty    int    `json:"dirty,omitempty"` // changed and untracked files
+	DiffStat string `json:"diffstat,omitempty"`
+}
+
+func (s State) IsZero() bool {
+	return s == State{}
+}
+
+// String: main@1a2b3c4, 3 dirty (2 files changed, 10 insertions(+))
+func (s State) String() string {
+	b := s.Branch
+	if s.Head != "" {
+		b += "@" + s.Head
+	}
+	if s.Dirty > 0 {
+		b += fmt.Sprintf(", %d dirty", s.Dirty)
+	}
+	if s.DiffStat != "" {
+		b += " (" + s.DiffStat + ")"
+	}
+	return b
+}
+
+// FrontMatter: the state as front matter lines, none outside of a repository
+func (s State) FrontMatter() string {
+	if s.IsZero() {
+		return ""
+	}
+	return fmt.Sprintf("branch: %s\nhead: %s\ndirty: %d\ndiffstat: %s\n", s.Branch, s.Head, s.Dirty, s.DiffStat)
+}
+
+// ParseFrontMatter: sets the part of the state a front matter line holds, false when
+// the key isn't one of them
+func (s *State) ParseFrontMatter(k string, v string) bool {
+	switch k {
+	case "branch":
+		s.Branch = v
+	case "head":
+		s.Head = v
+	case "dirty":
+		s.Dirty, _ = strconv.Atoi(v)
+	case "diffstat":
+		s.DiffStat = v
+	default:
+		return false
+	}
+	return true
+}
+
+// Capture: the state of the repository in dir, the zero state outside of one and in a
+// directory nested inside one, whose state would be the parent's. excluded paths don't
+// count as dirty. parts git can't tell, like the head of a repository without commits,
+// are left empty.
+func Capture(dir string, excluded ...string) State {
+	var s State
+	if !IsTopLevel(dir) {
+		return s
+	}
+	s.Branch, _ = Branch(dir)
+	if s.Branch == "" || s.Branch == "HEAD" {
+		if b, err := run(dir, "symbolic-ref", "--short", "HEAD"); err == nil {
+			s.Branch = b
+		}
+	}
+	s.Head, _ = run(dir, "rev-parse", "--short", "HEAD")
+	args := []string{"status", "--porcelain", "--", "."}
+	for _, e := range excluded {
+		args = append(args, ":!"+e)
+	}
+	if status, err := run(dir, args...); err == nil && status != "" {
+		s.Dirty = len(strings.Split(status, "\n"))
+	}
+	if s.Head != "" {
+		s.DiffStat, _ = run(dir, "diff", "HEAD", "--shortstat")
+	}
+	return s
+}
+
+// RecentCommits: one line per commit, newest first
+func RecentCommits(dir string, n int) ([]string, error) {
+	out, err := run(dir, "log", "--oneline", "--no-decorate", "-n", strconv.Itoa(n))
+	if err != nil || out == "" {
+		return nil, err
+	}
+	return strings.Split(out, "\n"), nil
+}
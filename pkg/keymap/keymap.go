@@ -0,0 +1,177 @@
+package keymap
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+const (
+	UNBOUND string = "none" // binding a key to this action removes a default binding
+)
+
+// Binding: key sequence and the action it triggers. sequences are space separated key
+// names as reported by bubbletea, e.g. "g g" or "ctrl+d".
+type Binding struct {
+	Keys   string
+	Action string
+}
+
+// Keymap: key sequences to action names, per mode. a keymap tracks the keys typed so far
+// for multi-key sequences, so it's shared by pointer.
+type Keymap struct {
+	modes   map[string]map[string]string
+	pending []string
+}
+
+// normalizeKey: config files use "space" where bubbletea reports " "
+func normalizeKey(k string) string {
+	if k == "space" {
+		return " "
+	}
+	return k
+}
+
+func displayKey(k string) string {
+	if k == " " {
+		return "space"
+	}
+	return k
+}
+
+func normalizeSequence(seq string) string {
+	keys := strings.Fields(seq)
+	for i := range keys {
+		keys[i] = normalizeKey(keys[i])
+	}
+	return strings.Join(keys, "\x00")
+}
+
+// New: the default bindings by mode with the overrides applied on top. overrides are
+// mode -> keys -> action, as found in the config file.
+func New(defaults map[string][]Binding, overrides map[string]map[string]string) *Keymap {
+	k := &Keymap{modes: map[string]map[string]string{}}
+	for mode, bindings := range defaults {
+		for i := range bindings {
+			k.bind(mode, bindings[i].Keys, bindings[i].Action)
+		}
+	}
+	for mode, bindings := range overrides {
+		for keys, action := range bindings {
+			k.bind(mode, keys, action)
+		}
+	}
+	return k
+}
+
+func (k *Keymap) bind(mode, keys, action string) {
+	if k.modes[mode] == nil {
+		k.modes[mode] = map[string]string{}
+	}
+	seq := normalizeSequence(keys)
+	if action == UNBOUND {
+		delete(k.modes[mode], seq)
+		return
+	}
+	k.modes[mode][seq] = action
+}
+
+// Validate: reports bindings to unknown modes or actions, and sequences shadowed by a
+// shorter sequence bound in the same mode. actions lists the known actions by mode.
+func (k *Keymap) Validate(actions map[string][]string) error {
+	errs := []error{}
+	for _, mode := range slices.Sorted(maps.Keys(k.modes)) {
+		known, ok := actions[mode]
+		if !ok {
+			errs = append(errs, fmt.Errorf("unknown mode '%s'", mode))
+			continue
+		}
+		seqs := slices.Sorted(maps.Keys(k.modes[mode]))
+		for _, seq := range seqs {
+			if action := k.modes[mode][seq]; !slices.Contains(known, action) {
+				errs = append(errs, fmt.Errorf("%s: '%s' is bound to unknown action '%s'", mode, displaySequence(seq), action))
+			}
+			for _, other := range seqs {
+				if other != seq && strings.HasPrefix(other, seq+"\x00") {
+					errs = append(errs, fmt.Errorf("%s: '%s' (%s) shadows '%s' (%s)", mode,
+						displaySequence(seq), k.modes[mode][seq], displaySequence(other), k.modes[mode][other]))
+				}
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func displaySequence(seq string) string {
+	keys := strings.Split(seq, "\x00")
+	for i := range keys {
+		keys[i] = displayKey(keys[i])
+	}
+	return strings.Join(keys, " ")
+}
+
+// Resolve: feeds a key typed in mode. returns the action once a bound sequence completes,
+// or pending while the keys typed so far start a longer sequence. unbound keys reset the
+// sequence and resolve to nothing.
+func (k *Keymap) Resolve(mode, key string) (action string, pending bool) {
+	seq := strings.Join(append(k.pending, key), "\x00")
+	if action, ok := k.modes[mode][seq]; ok {
+		k.pending = nil
+		return action, false
+	}
+	for s := range k.modes[mode] {
+		if strings.HasPrefix(s, seq+"\x00") {
+			k.pending = append(k.pending, key)
+			return "", true
+		}
+	}
+	if len(k.pending) > 0 {
+		// the sequence broke off, the key may still start a new one
+		k.pending = nil
+		return k.Resolve(mode, key)
+	}
+	return "", false
+}
+
+// Pending: keys typed so far of an unfinished sequence
+func (k *Keymap) Pending() string {
+	return displaySequence(strings.Join(k.pending, "\x00"))
+}
+
+func (k *Keymap) Reset() {
+	k.pending = nil
+}
+
+// KeysFor: the sequences bound to action in mode, sorted by length then name
+func (k *Keymap) KeysFor(mode, action string) []string {
+	keys := []string{}
+	for seq, a := range k.modes[mode] {
+		if a == action {
+			keys = append(keys, displaySequence(seq))
+		}
+	}
+	slices.SortFunc(keys, func(a, b string) int {
+		if len(a) != len(b) {
+			return len(a) - len(b)
+		}
+		return strings.Compare(a, b)
+	})
+	return keys
+}
+
+// Bindings: every binding of mode, sorted by action
+func (k *Keymap) Bindings(mode string) []Binding {
+	b := make([]Binding, 0, len(k.modes[mode]))
+	for seq, action := range k.modes[mode] {
+		b = append(b, Binding{Keys: displaySequence(seq), Action: action})
+	}
+	slices.SortFunc(b, func(x, y Binding) int {
+		if c := strings.Compare(x.Action, y.Action); c != 0 {
+			return c
+		}
+		return strings.Compare(x.Keys, y.Keys)
+	})
+	return b
+}
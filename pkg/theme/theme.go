@@ -0,0 +1,202 @@
+package theme
+
+import (
+	"fmt"
+	"maps"
+	"strconv"
+	"strings"
+
+	"github.com/muesli/termenv"
+)
+
+// StyleName: role of a piece of text, themes map each role to a style
+type StyleName = string
+
+const (
+	SELECTED       StyleName = "selected"       // name of the row under the cursor
+	SELECTED_INDEX StyleName = "selected_index" // index of the row under the cursor
+	MARKED         StyleName = "marked"         // mark of the rows batch actions run on
+	INDEX          StyleName = "index"
+	NAME           StyleName = "name"
+	PATH           StyleName = "path"
+	AGE_DAY        StyleName = "age_day" // modified within a day
+	AGE_WEEK       StyleName = "age_week"
+	AGE_MONTH      StyleName = "age_month"
+	AGE_OLD        StyleName = "age_old"
+	FOOTER         StyleName = "footer" // hints and secondary text
+	PROMPT         StyleName = "prompt" // filter and palette input, section titles
+	KEY            StyleName = "key"    // key bindings
+	TITLE          StyleName = "title"
+	LABEL          StyleName = "label"
+	SUCCESS        StyleName = "success"
+	WARNING        StyleName = "warning"
+	ERROR          StyleName = "error"
+
+	DEFAULT_THEME string = "dark"
+)
+
+// Style: colors are hex ("#5f87ff") or ANSI codes ("4", "69"), and degrade to what the
+// terminal supports
+type Style struct {
+	Fg        string `json:"fg,omitempty"`
+	Bg        string `json:"bg,omitempty"`
+	Bold      bool   `json:"bold,omitempty"`
+	Faint     bool   `json:"faint,omitempty"`
+	Underline bool   `json:"underline,omitempty"`
+}
+
+// UserTheme: theme from the config. styles missing from it are taken from the base theme.
+type UserTheme struct {
+	Base   string              `json:"base"`
+	Styles map[StyleName]Style `json:"styles"`
+}
+
+type Theme struct {
+	name    string
+	styles  map[StyleName]Style
+	profile termenv.Profile
+}
+
+var (
+	Builtin map[string]map[StyleName]Style = map[string]map[StyleName]Style{
+		"dark": {
+			SELECTED:       {Fg: "4", Bold: true},
+			SELECTED_INDEX: {Fg: "4", Bold: true},
+			MARKED:         {Fg: "5", Bold: true},
+			PATH:           {Fg: "8"},
+			AGE_DAY:        {Fg: "4"},
+			AGE_WEEK:       {Fg: "2"},
+			AGE_MONTH:      {Fg: "3"},
+			AGE_OLD:        {Fg: "1"},
+			FOOTER:         {Fg: "8"},
+			PROMPT:         {Fg: "3"},
+			KEY:            {Fg: "4"},
+			TITLE:          {Fg: "4"},
+			LABEL:          {Fg: "8"},
+			SUCCESS:        {Fg: "2"},
+			WARNING:        {Fg: "3"},
+			ERROR:          {Fg: "1", Bold: true},
+		},
+		"light": {
+			SELECTED:       {Fg: "#005fd7", Bold: true},
+			SELECTED_INDEX: {Fg: "#005fd7", Bold: true},
+			MARKED:         {Fg: "#af00af", Bold: true},
+			PATH:           {Fg: "#6c6c6c"},
+			AGE_DAY:        {Fg: "#005fd7"},
+			AGE_WEEK:       {Fg: "#008700"},
+			AGE_MONTH:      {Fg: "#af5f00"},
+			AGE_OLD:        {Fg: "#af0000"},
+			FOOTER:         {Fg: "#6c6c6c"},
+			PROMPT:         {Fg: "#875f00", Bold: true},
+			KEY:            {Fg: "#005fd7"},
+			TITLE:          {Fg: "#005fd7", Bold: true},
+			LABEL:          {Fg: "#6c6c6c"},
+			SUCCESS:        {Fg: "#008700"},
+			WARNING:        {Fg: "#af5f00"},
+			ERROR:          {Fg: "#af0000", Bold: true},
+		},
+		"high-contrast": {
+			SELECTED:       {Fg: "0", Bg: "15", Bold: true},
+			SELECTED_INDEX: {Fg: "0", Bg: "15", Bold: true},
+			MARKED:         {Fg: "13", Bold: true},
+			NAME:           {Fg: "15"},
+			INDEX:          {Fg: "15"},
+			PATH:           {Fg: "15", Underline: true},
+			AGE_DAY:        {Fg: "14", Bold: true},
+			AGE_WEEK:       {Fg: "10", Bold: true},
+			AGE_MONTH:      {Fg: "11", Bold: true},
+			AGE_OLD:        {Fg: "9", Bold: true},
+			FOOTER:         {Fg: "15"},
+			PROMPT:         {Fg: "11", Bold: true},
+			KEY:            {Fg: "14", Bold: true},
+			TITLE:          {Fg: "15", Bold: true, Underline: true},
+			LABEL:          {Fg: "15", Bold: true},
+			SUCCESS:        {Fg: "10", Bold: true},
+			WARNING:        {Fg: "11", Bold: true},
+			ERROR:          {Fg: "9", Bold: true},
+		},
+	}
+	active *Theme = &Theme{name: DEFAULT_THEME, styles: Builtin[DEFAULT_THEME], profile: DetectProfile()}
+)
+
+func validateColor(c string) error {
+	if c == "" || strings.HasPrefix(c, "#") && len(c) == 7 {
+		return nil
+	}
+	if i, err := strconv.Atoi(c); err == nil && i >= 0 && i < 256 {
+		return nil
+	}
+	return fmt.Errorf("invalid color '%s'", c)
+}
+
+// New: builtin or user theme by name. the profile decides how far colors degrade,
+// DetectProfile honours NO_COLOR.
+func New(name string, user map[string]UserTheme, profile termenv.Profile) (*Theme, error) {
+	if styles, ok := Builtin[name]; ok {
+		return &Theme{name: name, styles: styles, profile: profile}, nil
+	}
+	u, ok := user[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown theme '%s'", name)
+	}
+	base := u.Base
+	if base == "" {
+		base = DEFAULT_THEME
+	}
+	styles, ok := Builtin[base]
+	if !ok {
+		return nil, fmt.Errorf("theme '%s': unknown base theme '%s'", name, base)
+	}
+	styles = maps.Clone(styles)
+	for n, s := range u.Styles {
+		if err := validateColor(s.Fg); err != nil {
+			return nil, fmt.Errorf("theme '%s': %s: %w", name, n, err)
+		}
+		if err := validateColor(s.Bg); err != nil {
+			return nil, fmt.Errorf("theme '%s': %s: %w", name, n, err)
+		}
+		styles[n] = s
+	}
+	return &Theme{name: name, styles: styles, profile: profile}, nil
+}
+
+func DetectProfile() termenv.Profile {
+	return termenv.EnvColorProfile()
+}
+
+func (t *Theme) Name() string {
+	return t.name
+}
+
+func (t *Theme) Render(name StyleName, text string) string {
+	s, ok := t.styles[name]
+	if !ok {
+		return text
+	}
+	style := t.profile.String(text)
+	if c := t.profile.Color(s.Fg); c != nil {
+		style = style.Foreground(c)
+	}
+	if c := t.profile.Color(s.Bg); c != nil {
+		style = style.Background(c)
+	}
+	if s.Bold {
+		style = style.Bold()
+	}
+	if s.Faint {
+		style = style.Faint()
+	}
+	if s.Underline {
+		style = style.Underline()
+	}
+	return style.String()
+}
+
+// Use: makes t the theme Render draws with
+func Use(t *Theme) {
+	active = t
+}
+
+func Render(name StyleName, text string) string {
+	return active.Render(name, text)
+}
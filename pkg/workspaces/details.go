@@ -0,0 +1,119 @@
+package workspaces
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+var (
+	languageExtensions map[string]string = map[string]string{
+		".go":    "Go",
+		".rs":    "Rust",
+		".py":    "Python",
+		".js":    "JavaScript",
+		".jsx":   "JavaScript",
+		".ts":    "TypeScript",
+		".tsx":   "TypeScript",
+		".java":  "Java",
+		".kt":    "Kotlin",
+		".c":     "C",
+		".h":     "C",
+		".cpp":   "C++",
+		".cc":    "C++",
+		".hpp":   "C++",
+		".cs":    "C#",
+		".rb":    "Ruby",
+		".php":   "PHP",
+		".swift": "Swift",
+		".lua":   "Lua",
+		".sh":    "Shell",
+		".sql":   "SQL",
+		".html":  "HTML",
+		".css":   "CSS",
+		".zig":   "Zig",
+	}
+	skippedDirs map[string]bool = map[string]bool{
+		".git":         true,
+		"node_modules": true,
+		"vendor":       true,
+		"target":       true,
+		".venv":        true,
+	}
+)
+
+// ReadmeExcerpt: first maxlines non-empty lines of the workspace README, if any
+func (w *Workspace) ReadmeExcerpt(maxlines int) (string, error) {
+	entries, err := os.ReadDir(w.Path())
+	if err != nil {
+		return "", err
+	}
+	for i := range entries {
+		if entries[i].IsDir() || !strings.HasPrefix(strings.ToLower(entries[i].Name()), "readme") {
+			continue
+		}
+		f, err := os.Open(path.Join(w.Path(), entries[i].Name()))
+		if err != nil {
+			return "", err
+		}
+		defer f.Close()
+		lines := make([]string, 0, maxlines)
+		s := bufio.NewScanner(f)
+		for len(lines) < maxlines && s.Scan() {
+			if l := strings.TrimSpace(s.Text()); l != "" {
+				lines = append(lines, l)
+			}
+		}
+		return strings.Join(lines, "\n"), s.Err()
+	}
+	return "", nil
+}
+
+// Languages: detected languages ordered by file count, and the total size in bytes of the workspace.
+// both come from the same walk so the tree is only traversed once. vendored and generated
+// directories are skipped, walking them is most of the cost and says little about the
+// workspace. the walk stops early once ctx is done.
+func (w *Workspace) Languages(ctx context.Context) ([]string, int64, error) {
+	var (
+		size   int64
+		counts map[string]int = map[string]int{}
+	)
+	err := filepath.WalkDir(w.Path(), func(p string, d fs.DirEntry, err error) error {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			if p != w.Path() && skippedDirs[d.Name()] {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info, err := d.Info(); err == nil {
+			size += info.Size()
+		}
+		if l, ok := languageExtensions[strings.ToLower(filepath.Ext(d.Name()))]; ok {
+			counts[l]++
+		}
+		return nil
+	})
+	langs := make([]string, 0, len(counts))
+	for l := range counts {
+		langs = append(langs, l)
+	}
+	slices.SortFunc(langs, func(a, b string) int {
+		if c := cmp.Compare(counts[b], counts[a]); c != 0 {
+			return c
+		}
+		return cmp.Compare(a, b)
+	})
+	return langs, size, err
+}
@@ -0,0 +1,137 @@
+package workspaces
+
+import (
+	"os"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// Watcher: reloads the workspaces of a root whenever entries are added, removed or renamed
+// in it. a burst of events, like a git clone or an rm -r, ends up as a single reload.
+type Watcher struct {
+	root     string
+	debounce time.Duration
+	fs       *fsnotify.Watcher
+	known    map[string]bool // names of the loaded workspaces
+	changes  chan []Workspace
+	errors   chan error
+	done     chan struct{} // closed by Close, nobody may be receiving anymore
+	closing  sync.Once
+}
+
+func Watch(root string, debounce time.Duration) (*Watcher, error) {
+	fs, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	if err := fs.Add(root); err != nil {
+		fs.Close()
+		return nil, err
+	}
+	ws, err := Load(root)
+	if err != nil {
+		fs.Close()
+		return nil, err
+	}
+	w := &Watcher{
+		root:     root,
+		debounce: debounce,
+		fs:       fs,
+		known:    names(ws),
+		changes:  make(chan []Workspace),
+		errors:   make(chan error),
+		done:     make(chan struct{}),
+	}
+	go w.run()
+	return w, nil
+}
+
+func (w *Watcher) run() {
+	defer close(w.changes)
+	timer := time.NewTimer(w.debounce)
+	timer.Stop()
+	for {
+		select {
+		case <-w.done:
+			return
+		case e, ok := <-w.fs.Events:
+			if !ok {
+				return
+			}
+			if w.isRelevant(e) {
+				timer.Reset(w.debounce)
+			}
+		case err, ok := <-w.fs.Errors:
+			if !ok || !w.sendError(err) {
+				return
+			}
+		case <-timer.C:
+			ws, err := Load(w.root)
+			if err != nil {
+				if !w.sendError(err) {
+					return
+				}
+				continue
+			}
+			w.known = names(ws)
+			select {
+			case w.changes <- ws:
+			case <-w.done:
+				return
+			}
+		}
+	}
+}
+
+// sendError: false once the watcher is closed
+func (w *Watcher) sendError(err error) bool {
+	select {
+	case w.errors <- err:
+		return true
+	case <-w.done:
+		return false
+	}
+}
+
+func names(ws []Workspace) map[string]bool {
+	known := make(map[string]bool, len(ws))
+	for i := range ws {
+		known[ws[i].DirEntry.Name()] = true
+	}
+	return known
+}
+
+// isRelevant: whether e may change the list. files living in the root, like the database
+// and its journal, come and go all the time and are ignored.
+func (w *Watcher) isRelevant(e fsnotify.Event) bool {
+	name := path.Base(e.Name)
+	switch {
+	case e.Has(fsnotify.Create):
+		info, err := os.Stat(e.Name)
+		return err == nil && info.IsDir() && name != ARCHIVE_DIR
+	case e.Has(fsnotify.Remove), e.Has(fsnotify.Rename):
+		return w.known[name]
+	}
+	return false
+}
+
+// Changes: the workspaces after each change, closed once the watcher is
+func (w *Watcher) Changes() <-chan []Workspace {
+	return w.changes
+}
+
+func (w *Watcher) Errors() <-chan error {
+	return w.errors
+}
+
+func (w *Watcher) Close() error {
+	var err error
+	w.closing.Do(func() {
+		close(w.done)
+		err = w.fs.Close()
+	})
+	return err
+}
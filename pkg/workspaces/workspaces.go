@@ -1,10 +1,15 @@
 package workspaces
 
 import (
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 	"path"
+	"strings"
 	"time"
+
+	"github.com/google/uuid"
 )
 
 type Workspace struct {
@@ -30,8 +35,125 @@ func (w *Workspace) ModTime() time.Time {
 	}
 	return info.ModTime()
 }
+
+const (
+	ARCHIVE_DIR string = ".archive"          // archived workspaces are moved here, next to the others
+	ID_FILE     string = ".workspacescli-id" // stable id of the workspace, follows it when it is moved
+)
+
+// ID: the id kept in the workspace, empty when it has none yet. anyone can edit the
+// file and repositories may ship one, so anything but a uuid is an error: stores build
+// paths from it.
+func (w *Workspace) ID() (string, error) {
+	data, err := os.ReadFile(path.Join(w.Path(), ID_FILE))
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+	id, err := uuid.Parse(strings.TrimSpace(string(data)))
+	if err != nil {
+		return "", fmt.Errorf("%s: not a uuid", ID_FILE)
+	}
+	return id.String(), nil
+}
+
+// SetID: writes the id file, which repositories are told to ignore
+func (w *Workspace) SetID(id string) error {
+	if err := os.WriteFile(path.Join(w.Path(), ID_FILE), []byte(id+"\n"), 0o644); err != nil {
+		return err
+	}
+	return w.ExcludeID()
+}
+
+// gitDir: the git directory of the repository the workspace is, empty when it isn't one.
+// worktrees and submodules have a .git file pointing at theirs, worktrees share the
+// exclude file of the main repository.
+func (w *Workspace) gitDir() string {
+	dotgit := path.Join(w.Path(), ".git")
+	info, err := os.Stat(dotgit)
+	if err != nil {
+		return ""
+	}
+	if info.IsDir() {
+		return dotgit
+	}
+	data, err := os.ReadFile(dotgit)
+	if err != nil {
+		return ""
+	}
+	dir, ok := strings.CutPrefix(strings.TrimSpace(string(data)), "gitdir:")
+	if !ok {
+		return ""
+	}
+	if dir = strings.TrimSpace(dir); !path.IsAbs(dir) {
+		dir = path.Join(w.Path(), dir)
+	}
+	if common, err := os.ReadFile(path.Join(dir, "commondir")); err == nil {
+		c := strings.TrimSpace(string(common))
+		if !path.IsAbs(c) {
+			c = path.Join(dir, c)
+		}
+		return c
+	}
+	return dir
+}
+
+// ExcludeID: adds ID_FILE to the info/exclude file of the repository, so that it neither
+// shows up in git status nor gets committed. nothing to do outside of repositories.
+func (w *Workspace) ExcludeID() error {
+	dir := w.gitDir()
+	if dir == "" {
+		return nil
+	}
+	file := path.Join(dir, "info", "exclude")
+	data, err := os.ReadFile(file)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	for _, l := range strings.Split(string(data), "\n") {
+		if l = strings.TrimSpace(l); l == ID_FILE || l == "/"+ID_FILE {
+			return nil
+		}
+	}
+	if err := os.MkdirAll(path.Dir(file), 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	line := "/" + ID_FILE + "\n"
+	if len(data) > 0 && !strings.HasSuffix(string(data), "\n") {
+		line = "\n" + line
+	}
+	_, err = f.WriteString(line)
+	return errors.Join(err, f.Close())
+}
+
+// Archive: moves the workspace into ARCHIVE_DIR of its parent, the archived workspace
+// is returned
+func (w *Workspace) Archive() (Workspace, error) {
+	dir := path.Join(w.Parent, ARCHIVE_DIR)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return Workspace{}, err
+	}
+	target := path.Join(dir, w.DirEntry.Name())
+	if _, err := os.Lstat(target); err == nil {
+		return Workspace{}, fmt.Errorf("'%s' already exists", target)
+	}
+	if err := os.Rename(w.Path(), target); err != nil {
+		return Workspace{}, err
+	}
+	info, err := os.Lstat(target)
+	if err != nil {
+		return Workspace{}, err
+	}
+	return Workspace{Parent: dir, DirEntry: fs.FileInfoToDirEntry(info)}, nil
+}
+
 func isIgnored(d os.DirEntry) bool {
-	return d.Name() == ".DS_Store" || !d.IsDir()
+	return d.Name() == ".DS_Store" || d.Name() == ARCHIVE_DIR || !d.IsDir()
 }
 
 func Load(path string) ([]Workspace, error) {
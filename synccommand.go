@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"workspaces-cli/models/db"
+	"workspaces-cli/models/gitsync"
+	"workspaces-cli/pkg/config"
+	"workspaces-cli/pkg/theme"
+	"workspaces-cli/pkg/workspaces"
+)
+
+// syncRemote: the configured remote, local paths made absolute since git runs in the clone
+func syncRemote(cfg config.Config) (string, error) {
+	remote := os.ExpandEnv(cfg.SyncRemote)
+	if remote == "" || strings.Contains(remote, ":") || path.IsAbs(remote) {
+		return remote, nil
+	}
+	return filepath.Abs(remote)
+}
+
+// syncCommand: exchanges checkpoints with the other machines through the sync repository
+func syncCommand(ctx context.Context, cfg config.Config, store db.Store, ws []workspaces.Workspace) error {
+	remote, err := syncRemote(cfg)
+	if err != nil {
+		return err
+	}
+	dir := os.ExpandEnv(cfg.SyncDir)
+	if dir == "" {
+		d, err := config.DataDir()
+		if err != nil {
+			return err
+		}
+		dir = path.Join(d, "sync")
+	}
+	if remote == "" {
+		fmt.Println(theme.Render(theme.WARNING, fmt.Sprintf("no sync_remote configured, checkpoints are only written to %s", dir)))
+	}
+	r, err := gitsync.Sync(ctx, store, ws, dir, remote)
+	if err != nil {
+		return err
+	}
+	fmt.Println(theme.Render(theme.SUCCESS, fmt.Sprintf("pulled %d checkpoint(s), pushed %d", r.Pulled, r.Pushed)))
+	if r.Unmatched > 0 {
+		fmt.Println(theme.Render(theme.FOOTER, fmt.Sprintf("%d checkpoint(s) belong to workspaces this machine doesn't have", r.Unmatched)))
+	}
+	return nil
+}